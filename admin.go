@@ -0,0 +1,168 @@
+package hmac
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxAdminRequestSize limits the body of admin API requests.
+const maxAdminRequestSize = 1 << 20
+
+// NewAdminHandler returns an http.Handler serving an API to manage the
+// credentials of manager. Every request must be signed by a credential
+// accepted by admin, which must be an Authenticator for a separate admin
+// key pair rather than one whose credential store is manager. admin must
+// have a NonceStore, see WithNonceStore, since a replayed request would issue
+// a new secret to whoever replayed it.
+//
+// The handler serves, relative to where it is mounted:
+//
+//	GET  /credentials              list credentials
+//	POST /credentials              create a credential
+//	GET  /credentials/{id}         show a credential
+//	POST /credentials/{id}/rotate  rotate its secret
+//	POST /credentials/{id}/revoke  revoke it
+//	GET  /credentials/{id}/usage   show its usage
+//
+// Creating a credential takes an optional JSON body with id, metadata and
+// expires_at, and rotating one an optional JSON body with overlap, a
+// duration such as "1h" defaulting to DefaultRotationOverlap. Both respond
+// with the credential and its new hex encoded secret, which is not shown
+// again.
+func NewAdminHandler(manager *CredentialManager, admin *Authenticator) (http.Handler, error) {
+	if manager == nil {
+		return nil, fmt.Errorf("credential manager required")
+	}
+	if admin == nil {
+		return nil, fmt.Errorf("admin authenticator required")
+	}
+	if store, ok := admin.credentials.(*CredentialManager); ok && store == manager {
+		return nil, fmt.Errorf("admin authenticator must not accept the credentials it manages")
+	}
+	if admin.nonceStore == nil {
+		return nil, fmt.Errorf("admin authenticator requires a nonce store")
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /credentials", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, manager.List())
+	})
+
+	mux.HandleFunc("POST /credentials", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ID        string            `json:"id"`
+			Metadata  map[string]string `json:"metadata"`
+			ExpiresAt time.Time         `json:"expires_at"`
+		}
+		if !readAdminJSON(w, r, &body) {
+			return
+		}
+
+		credential, secret, err := manager.Create(body.ID, body.Metadata, body.ExpiresAt)
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusCreated, issuedCredential{credential, secret})
+	})
+
+	mux.HandleFunc("GET /credentials/{id}", func(w http.ResponseWriter, r *http.Request) {
+		credential, ok := manager.Get(r.PathValue("id"))
+		if !ok {
+			writeAdminError(w, ErrCredentialNotFound)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, credential)
+	})
+
+	mux.HandleFunc("POST /credentials/{id}/rotate", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Overlap string `json:"overlap"`
+		}
+		if !readAdminJSON(w, r, &body) {
+			return
+		}
+
+		overlap := DefaultRotationOverlap
+		if body.Overlap != "" {
+			var err error
+			overlap, err = time.ParseDuration(body.Overlap)
+			if err != nil || overlap < 0 {
+				http.Error(w, fmt.Sprintf("invalid overlap %q", body.Overlap), http.StatusBadRequest)
+				return
+			}
+		}
+
+		credential, secret, err := manager.Rotate(r.PathValue("id"), overlap)
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, issuedCredential{credential, secret})
+	})
+
+	mux.HandleFunc("POST /credentials/{id}/revoke", func(w http.ResponseWriter, r *http.Request) {
+		credential, err := manager.Revoke(r.PathValue("id"))
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, credential)
+	})
+
+	mux.HandleFunc("GET /credentials/{id}/usage", func(w http.ResponseWriter, r *http.Request) {
+		usage, ok := manager.Usage(r.PathValue("id"))
+		if !ok {
+			writeAdminError(w, ErrCredentialNotFound)
+			return
+		}
+		writeAdminJSON(w, http.StatusOK, usage)
+	})
+
+	return admin.Middleware(mux), nil
+}
+
+// issuedCredential is a credential with its newly issued secret.
+type issuedCredential struct {
+	ManagedCredential
+	Secret string `json:"secret"`
+}
+
+// readAdminJSON decodes the body of r into v, if there is one. It reports
+// whether the body was valid, replying with an error otherwise.
+func readAdminJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminRequestSize)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrCredentialNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrCredentialExists), errors.Is(err, ErrCredentialRevoked):
+		status = http.StatusConflict
+	case errors.Is(err, ErrInvalidCredentialID):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
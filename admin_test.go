@@ -0,0 +1,207 @@
+package hmac
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type adminClient struct {
+	t       *testing.T
+	server  *httptest.Server
+	service *RequestService
+}
+
+func (c adminClient) do(method string, path string, body string, v any) int {
+	c.t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	request, _ := http.NewRequest(method, c.server.URL+path, reader)
+	if c.service != nil {
+		if _, err := c.service.SignRequest(request); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	response, err := c.server.Client().Do(request)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if v != nil && response.StatusCode < 300 {
+		if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	return response.StatusCode
+}
+
+func newAdminClient(t *testing.T, manager *CredentialManager) adminClient {
+	t.Helper()
+
+	adminPublic := GenerateSecureRandom(16)
+	adminPrivate := GenerateSecureRandom(16)
+	admin, _ := NewAuthenticator(adminPublic, adminPrivate, 300, WithNonceStore(NewMemoryNonceStore(600*time.Second)))
+	service, _ := NewRequestService(adminPublic, adminPrivate)
+
+	handler, err := NewAdminHandler(manager, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return adminClient{t: t, server: server, service: service}
+}
+
+func TestThatAdminHandlerManagesCredentials(t *testing.T) {
+	manager := NewCredentialManager()
+	client := newAdminClient(t, manager)
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300,
+		WithCredentialStore(manager), WithObserver(manager))
+
+	var created struct {
+		ManagedCredential
+		Secret string `json:"secret"`
+	}
+	if code := client.do(http.MethodPost, "/credentials", `{"id": "orders", "metadata": {"team": "orders"}}`, &created); code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", code)
+	}
+	if created.ID != "orders" || created.Metadata["team"] != "orders" || len(created.Secret) != 64 {
+		t.Fatalf("unexpected credential %+v", created)
+	}
+	if _, err := authenticator.Authenticate(signedTestRequest(t, created.ID, created.Secret)); err != nil {
+		t.Fatal(err)
+	}
+	if code := client.do(http.MethodPost, "/credentials", `{"id": "orders"}`, nil); code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", code)
+	}
+
+	var rotated struct {
+		ManagedCredential
+		Secret string `json:"secret"`
+	}
+	if code := client.do(http.MethodPost, "/credentials/orders/rotate", `{"overlap": "1h"}`, &rotated); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if rotated.Secret == created.Secret || rotated.PreviousExpiresAt.IsZero() {
+		t.Fatalf("unexpected rotation %+v", rotated)
+	}
+	if code := client.do(http.MethodPost, "/credentials/orders/rotate", `{"overlap": "soon"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", code)
+	}
+
+	var listed []ManagedCredential
+	client.do(http.MethodGet, "/credentials", "", &listed)
+	if len(listed) != 1 || listed[0].LastUsedAt.IsZero() {
+		t.Fatalf("unexpected credentials %+v", listed)
+	}
+
+	var usage CredentialUsage
+	client.do(http.MethodGet, "/credentials/orders/usage", "", &usage)
+	if usage.Requests != 1 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	var revoked ManagedCredential
+	client.do(http.MethodPost, "/credentials/orders/revoke", "", &revoked)
+	if !revoked.Revoked {
+		t.Fatalf("expected credential to be revoked, got %+v", revoked)
+	}
+	if code := client.do(http.MethodGet, "/credentials/unknown", "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", code)
+	}
+}
+
+func TestThatAdminHandlerRequiresAdminCredential(t *testing.T) {
+	manager := NewCredentialManager()
+	_, secret, _ := manager.Create("orders", nil, time.Time{})
+	client := newAdminClient(t, manager)
+
+	client.service = nil
+	if code := client.do(http.MethodGet, "/credentials", "", nil); code == http.StatusOK {
+		t.Fatal("expected unsigned request to be rejected")
+	}
+
+	client.service, _ = NewRequestService("orders", secret)
+	if code := client.do(http.MethodGet, "/credentials", "", nil); code != http.StatusForbidden {
+		t.Fatalf("expected managed credential to be rejected with 403, got %d", code)
+	}
+}
+
+func TestThatAdminHandlerRequiresNonceStore(t *testing.T) {
+	admin, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300)
+
+	if _, err := NewAdminHandler(NewCredentialManager(), admin); err == nil {
+		t.Fatal("expected error for admin authenticator without nonce store")
+	}
+}
+
+func TestThatAdminHandlerRejectsUnsafeAuthenticators(t *testing.T) {
+	manager := NewCredentialManager()
+	if _, err := NewAdminHandler(manager, nil); err == nil {
+		t.Fatal("expected error for nil admin authenticator")
+	}
+
+	admin, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300,
+		WithNonceStore(NewMemoryNonceStore(600*time.Second)), WithCredentialStore(manager))
+	if _, err := NewAdminHandler(manager, admin); err == nil {
+		t.Fatal("expected error for admin authenticator accepting managed credentials")
+	}
+}
+
+func TestThatAdminHandlerRejectsIDsWithSlashes(t *testing.T) {
+	manager := NewCredentialManager()
+	client := newAdminClient(t, manager)
+
+	if code := client.do(http.MethodPost, "/credentials", `{"id": "orders/v2"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", code)
+	}
+	if _, _, err := manager.Create("a/b", nil, time.Time{}); !errors.Is(err, ErrInvalidCredentialID) {
+		t.Fatalf("expected ErrInvalidCredentialID, got %v", err)
+	}
+}
+
+func TestThatAdminHandlerRejectsReplayedRequests(t *testing.T) {
+	manager := NewCredentialManager()
+	client := newAdminClient(t, manager)
+
+	request, _ := http.NewRequest(http.MethodPost, client.server.URL+"/credentials", strings.NewReader(`{"id": "orders"}`))
+	client.service.SignRequest(request)
+	replayed := request.Clone(request.Context())
+	replayed.Body = io.NopCloser(strings.NewReader(`{"id": "orders"}`))
+
+	response, err := client.server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", response.StatusCode)
+	}
+
+	// Without replay protection, the replay would create the credential again.
+	delete(manager.credentials, "orders")
+	response, err = client.server.Client().Do(replayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden || !strings.Contains(string(body), "Nonce already used") {
+		t.Fatalf("expected replay to be rejected, got %d %s", response.StatusCode, body)
+	}
+	if _, ok := manager.Get("orders"); ok {
+		t.Fatal("expected replay not to create a credential")
+	}
+}
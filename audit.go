@@ -0,0 +1,234 @@
+package hmac
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// AuditEntry records a single validation performed by an Authenticator.
+type AuditEntry struct {
+	Time        time.Time `json:"time"`
+	Credential  string    `json:"credential"`
+	Method      string    `json:"method"`
+	Host        string    `json:"host"`
+	Path        string    `json:"path"`
+	Timestamp   string    `json:"timestamp"`
+	Nonce       string    `json:"nonce"`
+	ContentHash string    `json:"content_hash,omitempty"`
+	// Outcome is "ok" for valid requests and the failure Reason otherwise.
+	Outcome string `json:"outcome"`
+}
+
+// AuditSink receives an entry for every request validated by an
+// Authenticator, valid or not. When Audit fails for a valid request, the
+// request is rejected, so that no authenticated call goes unrecorded.
+type AuditSink interface {
+	Audit(entry AuditEntry) error
+}
+
+// AuditHead identifies the last entry of an audit log. Storing it outside
+// the log, for example in a database or a separate system, allows detecting
+// entries deleted from the end of the log.
+type AuditHead struct {
+	Seq uint64
+	MAC string
+}
+
+// maxAuditFieldLength bounds every string field of an audit entry, in bytes.
+// Fields are filled from the request before it is authenticated, so without a
+// bound a single request could write an entry too large to read back.
+const maxAuditFieldLength = 1024
+
+// auditField makes s safe to record: invalid UTF-8 is replaced, since it would
+// not survive a JSON round trip, and s is truncated to maxAuditFieldLength
+// bytes on a rune boundary.
+func auditField(s string) string {
+	s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	if len(s) <= maxAuditFieldLength {
+		return s
+	}
+
+	n := maxAuditFieldLength
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
+
+func (e AuditEntry) sanitized() AuditEntry {
+	e.Credential = auditField(e.Credential)
+	e.Method = auditField(e.Method)
+	e.Host = auditField(e.Host)
+	e.Path = auditField(e.Path)
+	e.Timestamp = auditField(e.Timestamp)
+	e.Nonce = auditField(e.Nonce)
+	e.ContentHash = auditField(e.ContentHash)
+	e.Outcome = auditField(e.Outcome)
+
+	return e
+}
+
+type auditRecord struct {
+	Seq  uint64 `json:"seq"`
+	Prev string `json:"prev"`
+	AuditEntry
+	MAC string `json:"mac,omitempty"`
+}
+
+// AuditWriter is an AuditSink that writes JSON lines to an io.Writer. Each
+// line carries a sequence number and the MAC of the previous line, and is
+// authenticated with HMAC-SHA256 under a dedicated audit key, so that
+// VerifyAuditLog detects deleted, reordered and modified entries.
+type AuditWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	key  []byte
+	head AuditHead
+}
+
+// NewAuditWriter creates an AuditWriter that starts a new log on w. The
+// audit key should not be used for anything else.
+func NewAuditWriter(w io.Writer, key []byte) (*AuditWriter, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("audit key required")
+	}
+
+	return &AuditWriter{w: w, key: key}, nil
+}
+
+// OpenAuditFile verifies the audit log at path, creating it if needed, and
+// returns an AuditWriter that appends to it, continuing its chain. The caller
+// closes the file.
+func OpenAuditFile(path string, key []byte) (*AuditWriter, *os.File, error) {
+	if len(key) == 0 {
+		return nil, nil, fmt.Errorf("audit key required")
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	head, err := VerifyAuditLog(f, key)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return &AuditWriter{w: f, key: key, head: head}, f, nil
+}
+
+// Audit appends entry to the log. String fields longer than 1024 bytes are
+// truncated.
+func (aw *AuditWriter) Audit(entry AuditEntry) error {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	record := auditRecord{
+		Seq:        aw.head.Seq + 1,
+		Prev:       aw.head.MAC,
+		AuditEntry: entry.sanitized(),
+	}
+	mac, err := auditMAC(record, aw.key)
+	if err != nil {
+		return err
+	}
+	record.MAC = mac
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := aw.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("unable to write audit entry: %w", err)
+	}
+
+	aw.head = AuditHead{Seq: record.Seq, MAC: record.MAC}
+
+	return nil
+}
+
+// Head returns the last entry written.
+func (aw *AuditWriter) Head() AuditHead {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	return aw.head
+}
+
+// ErrAuditLogTampered is returned by VerifyAuditLog when the log has been
+// modified.
+var ErrAuditLogTampered = errors.New("audit log tampered")
+
+// VerifyAuditLog checks every entry of an audit log written by AuditWriter
+// and returns its last entry. The error wraps ErrAuditLogTampered and names
+// the first line that fails verification. Entries deleted from the end of the
+// log can only be detected by comparing the returned head with one recorded
+// elsewhere.
+func VerifyAuditLog(r io.Reader, key []byte) (AuditHead, error) {
+	var head AuditHead
+
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(raw) == 0 {
+			return head, nil
+		}
+		if err != nil && err != io.EOF {
+			return head, err
+		}
+		raw = bytes.TrimSuffix(raw, []byte("\n"))
+
+		var record auditRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return head, fmt.Errorf("%w: line %d: malformed entry", ErrAuditLogTampered, line)
+		}
+
+		if record.Seq != head.Seq+1 || record.Prev != head.MAC {
+			return head, fmt.Errorf("%w: line %d: entry out of sequence", ErrAuditLogTampered, line)
+		}
+
+		// The MAC covers the entry as written by Audit, so any other
+		// encoding, such as one with added fields, is rejected.
+		if canonical, err := json.Marshal(record); err != nil || !bytes.Equal(canonical, raw) {
+			return head, fmt.Errorf("%w: line %d: malformed entry", ErrAuditLogTampered, line)
+		}
+
+		mac := record.MAC
+		record.MAC = ""
+		expected, err := auditMAC(record, key)
+		if err != nil {
+			return head, err
+		}
+		if !hmac.Equal([]byte(expected), []byte(mac)) {
+			return head, fmt.Errorf("%w: line %d: invalid MAC", ErrAuditLogTampered, line)
+		}
+
+		head = AuditHead{Seq: record.Seq, MAC: mac}
+	}
+}
+
+func auditMAC(record auditRecord, key []byte) (string, error) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
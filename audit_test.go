@@ -0,0 +1,193 @@
+package hmac
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func auditTestLog(t *testing.T, key []byte, entries int) []string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer, err := NewAuditWriter(&buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < entries; i++ {
+		if err := writer.Audit(AuditEntry{Method: "GET", Path: "/", Outcome: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func TestThatVerifyAuditLogAcceptsUnmodifiedLog(t *testing.T) {
+	key := []byte("audit key")
+	lines := auditTestLog(t, key, 3)
+
+	head, err := VerifyAuditLog(strings.NewReader(strings.Join(lines, "")), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Seq != 3 {
+		t.Fatalf("expected head at entry 3, got %d", head.Seq)
+	}
+}
+
+func TestThatVerifyAuditLogDetectsTampering(t *testing.T) {
+	key := []byte("audit key")
+	lines := auditTestLog(t, key, 3)
+
+	tests := map[string]string{
+		"deleted":   lines[0] + lines[2],
+		"reordered": lines[0] + lines[2] + lines[1],
+		"modified":  lines[0] + strings.Replace(lines[1], `"method":"GET"`, `"method":"PUT"`, 1) + lines[2],
+		"added":     lines[0] + strings.Replace(lines[1], `{"seq"`, `{"approved":true,"seq"`, 1) + lines[2],
+	}
+	for name, log := range tests {
+		_, err := VerifyAuditLog(strings.NewReader(log), key)
+		if !errors.Is(err, ErrAuditLogTampered) {
+			t.Fatalf("%s: expected ErrAuditLogTampered, got %v", name, err)
+		}
+	}
+}
+
+func TestThatVerifyAuditLogRejectsWrongKey(t *testing.T) {
+	lines := auditTestLog(t, []byte("audit key"), 1)
+
+	_, err := VerifyAuditLog(strings.NewReader(lines[0]), []byte("other key"))
+	if !errors.Is(err, ErrAuditLogTampered) {
+		t.Fatalf("expected ErrAuditLogTampered, got %v", err)
+	}
+}
+
+func TestThatOpenAuditFileContinuesChain(t *testing.T) {
+	key := []byte("audit key")
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	for i := 0; i < 2; i++ {
+		writer, f, err := OpenAuditFile(path, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Audit(AuditEntry{Outcome: "ok"})
+		f.Close()
+	}
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	head, err := VerifyAuditLog(f, key)
+	if err != nil || head.Seq != 2 {
+		t.Fatalf("expected valid log with 2 entries, got %+v, %v", head, err)
+	}
+}
+
+func TestThatAuthenticatorAuditsValidations(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	key := []byte("audit key")
+
+	var buf bytes.Buffer
+	writer, _ := NewAuditWriter(&buf, key)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithAuditSink(writer))
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	authenticator.Validate(signedRequest)
+
+	forgedRequest := signedTestRequest(t, publicKey, privateKey)
+	forgedRequest.Header.Set("Signature", "invalid signature")
+	authenticator.Validate(forgedRequest)
+
+	log := buf.String()
+	if !strings.Contains(log, `"outcome":"ok"`) || !strings.Contains(log, `"outcome":"invalid_signature"`) {
+		t.Fatalf("expected both outcomes in audit log, got %s", log)
+	}
+	if !strings.Contains(log, `"nonce":"`+signedRequest.Header.Get("X-Nonce")+`"`) {
+		t.Fatalf("expected nonce in audit log, got %s", log)
+	}
+	if _, err := VerifyAuditLog(strings.NewReader(log), key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type recordingAuditSink struct {
+	entries []AuditEntry
+}
+
+func (s *recordingAuditSink) Audit(entry AuditEntry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func TestThatAuditEntriesUseAuthenticatorClock(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	sink := &recordingAuditSink{}
+	requestService, _ := NewRequestService(publicKey, privateKey, WithSigningClock(func() time.Time { return now }))
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithAuditSink(sink), WithClock(func() time.Time { return now }))
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+	requestService.SignRequest(request)
+
+	if _, err := authenticator.Authenticate(request); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.entries) != 1 || !sink.entries[0].Time.Equal(now) {
+		t.Fatalf("expected entry at %v, got %+v", now, sink.entries)
+	}
+}
+
+type failingAuditSink struct{}
+
+func (failingAuditSink) Audit(entry AuditEntry) error {
+	return errors.New("disk full")
+}
+
+func TestThatAuthenticatorRejectsRequestWhenAuditFails(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithAuditSink(failingAuditSink{}))
+	isValid, err := authenticator.Validate(signedRequest)
+
+	if isValid {
+		t.Fatal("expected validation to fail")
+	}
+	assertValidationError(t, err, "Unable to record request")
+}
+
+func TestThatOversizedRequestsDoNotBreakAuditFile(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	key := []byte("audit key")
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	writer, f, err := OpenAuditFile(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithAuditSink(writer))
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/"+strings.Repeat("<", 400*1024)+"%ff", nil)
+	request.Header.Set("X-Nonce", strings.Repeat("<", 400*1024))
+	authenticator.Validate(request)
+	f.Close()
+
+	writer, f, err = OpenAuditFile(path, key)
+	if err != nil {
+		t.Fatalf("expected audit file to reopen, got %v", err)
+	}
+	defer f.Close()
+	if writer.Head().Seq != 1 {
+		t.Fatalf("expected 1 entry, got %d", writer.Head().Seq)
+	}
+}
@@ -5,19 +5,55 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 )
 
+// Reason identifies why a request failed validation.
+type Reason string
+
+const (
+	ReasonMissingHeader        Reason = "missing_header"
+	ReasonInvalidTimestamp     Reason = "invalid_timestamp"
+	ReasonTimestampOutOfBounds Reason = "timestamp_out_of_bounds"
+	ReasonUnknownCredential    Reason = "unknown_credential"
+	ReasonUnreadableBody       Reason = "unreadable_body"
+	ReasonMissingContentHash   Reason = "missing_content_hash"
+	ReasonInvalidContentHash   Reason = "invalid_content_hash"
+	ReasonInvalidSignature     Reason = "invalid_signature"
+	ReasonReplayedNonce        Reason = "replayed_nonce"
+	ReasonAuditFailed          Reason = "audit_failed"
+	ReasonInvalidScope         Reason = "invalid_scope"
+	ReasonForbiddenByPolicy    Reason = "forbidden_by_policy"
+	ReasonLockedOut            Reason = "locked_out"
+	ReasonCredentialRevoked    Reason = "credential_revoked"
+	ReasonCredentialExpired    Reason = "credential_expired"
+	ReasonVerifierFailed       Reason = "verifier_failed"
+	ReasonRateLimited          Reason = "rate_limited"
+)
+
 // ValidationError describes why a request failed validation. Code is a
-// suggested HTTP status code for the response.
+// suggested HTTP status code for the response and Reason a stable identifier
+// of the failure, suitable for metrics. Message is safe to return to the
+// client; it deliberately does not distinguish an unknown credential from an
+// invalid signature.
+//
+// In debug mode, a signature mismatch also reports the canonical request and
+// string to sign computed by the server, so that clients can compare them
+// with their own. See WithDebug.
 type ValidationError struct {
-	Code    int
-	Message string
+	Code             int
+	Reason           Reason
+	Message          string
+	CanonicalRequest string
+	StringToSign     string
+
+	rateLimit *RateLimitStatus
 }
 
 func (e *ValidationError) Error() string {
@@ -33,10 +69,23 @@ type NonceStore interface {
 }
 
 type Authenticator struct {
-	public        string
-	private       []byte
-	timeTolerance int64
-	nonceStore    NonceStore
+	public           string
+	private          SecretKey
+	verifier         Verifier
+	timeTolerance    int64
+	nonceStore       NonceStore
+	credentials      CredentialStore
+	debug            bool
+	observer         Observer
+	logger           *slog.Logger
+	auditSink        AuditSink
+	clock            func() time.Time
+	headerNames      HeaderNames
+	scope            *Scope
+	rateLimiter      RateLimiter
+	defaultRateLimit RateLimit
+	lockout          *Lockout
+	revocations      RevocationList
 }
 
 type AuthenticatorOption func(*Authenticator)
@@ -49,6 +98,73 @@ func WithNonceStore(store NonceStore) AuthenticatorOption {
 	}
 }
 
+// WithCredentialStore accepts requests signed by credentials in store in
+// addition to the key pair passed to NewAuthenticator.
+func WithCredentialStore(store CredentialStore) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.credentials = store
+	}
+}
+
+// WithDebug reports the server's canonical request and string to sign in
+// the ValidationError of every signature mismatch. It exposes how requests
+// are canonicalized and should not be enabled in production; use
+// Credential.Debug to debug a single client instead.
+func WithDebug() AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.debug = true
+	}
+}
+
+// WithObserver reports the outcome of every validation to observer.
+func WithObserver(observer Observer) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.observer = observer
+	}
+}
+
+// WithLogger logs rejected requests to logger at warning level for replayed
+// nonces, unknown credentials, clock skew and invalid signatures, and at info
+// level otherwise. Authenticated requests are logged at debug level. Keys and
+// signatures are never logged.
+func WithLogger(logger *slog.Logger) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.logger = logger
+	}
+}
+
+// WithAuditSink records every validation, valid or not, in sink. Valid
+// requests are rejected if they cannot be recorded.
+func WithAuditSink(sink AuditSink) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.auditSink = sink
+	}
+}
+
+// WithClock makes the Authenticator check request timestamps against now
+// instead of time.Now.
+func WithClock(now func() time.Time) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.clock = now
+	}
+}
+
+// WithHeaderNames accepts signature headers under the given names, for
+// clients configured with WithSigningHeaderNames.
+func WithHeaderNames(names HeaderNames) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.headerNames = names.withDefaults()
+	}
+}
+
+// WithScope only accepts scoped requests for the given environment and
+// service, signed on the day of their timestamp. See Scope.
+func WithScope(environment string, service string) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.scope = &Scope{Environment: environment, Service: service}
+	}
+}
+
 var requiredHeaders = []string{
 	"Authorization",
 	"Credential",
@@ -57,24 +173,51 @@ var requiredHeaders = []string{
 	"X-Nonce",
 }
 
+// NewAuthenticator returns an Authenticator for the key pair public and
+// private. The private key is parsed with ParseSecretKey.
 func NewAuthenticator(public string, private string, timeTolerance int64, options ...AuthenticatorOption) (*Authenticator, error) {
 	if len(public) == 0 {
 		return nil, fmt.Errorf("public key required")
 	}
 
-	if len(private) == 0 {
-		return nil, fmt.Errorf("private key required")
+	key, err := ParseSecretKey(private)
+	if err != nil {
+		return nil, err
 	}
 
-	b, err := hex.DecodeString(private)
-	if err != nil {
-		return nil, fmt.Errorf("malformed private key")
+	a := &Authenticator{
+		public:        public,
+		private:       key,
+		timeTolerance: timeTolerance,
+		clock:         time.Now,
+		headerNames:   DefaultHeaderNames,
+	}
+
+	for _, option := range options {
+		option(a)
+	}
+
+	return a, nil
+}
+
+// NewAuthenticatorWithVerifier returns an Authenticator like NewAuthenticator
+// whose primary credential is checked by verifier, so that its private key
+// can be held by a KMS or HSM. See NewKey.
+func NewAuthenticatorWithVerifier(public string, verifier Verifier, timeTolerance int64, options ...AuthenticatorOption) (*Authenticator, error) {
+	if len(public) == 0 {
+		return nil, fmt.Errorf("public key required")
+	}
+
+	if verifier == nil {
+		return nil, fmt.Errorf("verifier required")
 	}
 
 	a := &Authenticator{
 		public:        public,
-		private:       b,
+		verifier:      verifier,
 		timeTolerance: timeTolerance,
+		clock:         time.Now,
+		headerNames:   DefaultHeaderNames,
 	}
 
 	for _, option := range options {
@@ -88,62 +231,240 @@ func NewAuthenticator(public string, private string, timeTolerance int64, option
 // validation fails, the returned error is a *ValidationError. The request
 // body is restored so callers can still read it after validation.
 func (a *Authenticator) Validate(r *http.Request) (bool, error) {
+	_, err := a.Authenticate(r)
+	return err == nil, err
+}
+
+// Authenticate validates the request like Validate and, on success, returns
+// a Result describing the credential and signed values it carried.
+func (a *Authenticator) Authenticate(r *http.Request) (*Result, error) {
+	start := time.Now()
+	var event ValidationEvent
+
+	result, err := a.authenticate(r, &event)
+	if a.auditSink != nil {
+		if auditErr := a.audit(r, err); auditErr != nil && err == nil {
+			result, err = nil, &ValidationError{
+				Code:    http.StatusInternalServerError,
+				Reason:  ReasonAuditFailed,
+				Message: "Unable to record request",
+			}
+		}
+	}
+
+	event.Duration = time.Since(start)
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		event.Reason = validationErr.Reason
+	}
+	if a.observer != nil {
+		a.observer.ObserveValidation(event)
+	}
+	if a.logger != nil {
+		a.logValidation(r, event)
+	}
+
+	return result, err
+}
+
+func (a *Authenticator) audit(r *http.Request, err error) error {
+	outcome := reasonLabel("")
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		outcome = reasonLabel(validationErr.Reason)
+	}
+
+	header := a.headerNames.toDefault(r.Header)
+	entry := AuditEntry{
+		Time:        a.clock().UTC(),
+		Credential:  header.Get("Credential"),
+		Method:      r.Method,
+		Host:        r.Host,
+		Path:        r.URL.Path,
+		Timestamp:   header.Get("X-Timestamp"),
+		Nonce:       header.Get("X-Nonce"),
+		ContentHash: header.Get("X-Content-SHA256"),
+		Outcome:     outcome,
+	}
+
+	// The entry is recorded before the request is authenticated, so its
+	// fields are bounded for every sink, not only AuditWriter.
+	return a.auditSink.Audit(entry.sanitized())
+}
+
+func (a *Authenticator) logValidation(r *http.Request, event ValidationEvent) {
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("credential", r.Header.Get(a.headerNames.Credential)),
+	}
+
+	if event.Reason == "" {
+		a.logger.LogAttrs(r.Context(), slog.LevelDebug, "request authenticated", attrs...)
+		return
+	}
+
+	attrs = append(attrs, slog.String("reason", string(event.Reason)))
+	level := slog.LevelInfo
+	switch event.Reason {
+	case ReasonTimestampOutOfBounds:
+		attrs = append(attrs, slog.Duration("skew", event.Skew))
+		level = slog.LevelWarn
+	case ReasonReplayedNonce, ReasonUnknownCredential, ReasonInvalidSignature, ReasonInvalidScope,
+		ReasonForbiddenByPolicy, ReasonLockedOut, ReasonCredentialRevoked, ReasonCredentialExpired:
+		level = slog.LevelWarn
+	case ReasonVerifierFailed:
+		level = slog.LevelError
+	}
+	a.logger.LogAttrs(r.Context(), level, "request rejected", attrs...)
+}
+
+// LogValue implements slog.LogValuer. The private key is never included.
+func (a *Authenticator) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("public", a.public),
+		slog.Int64("time_tolerance", a.timeTolerance),
+	)
+}
+
+// LogValue implements slog.LogValuer. Debug details are omitted.
+func (e *ValidationError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("code", e.Code),
+		slog.String("reason", string(e.Reason)),
+		slog.String("message", e.Message),
+	)
+}
+
+func (a *Authenticator) authenticate(r *http.Request, event *ValidationEvent) (*Result, error) {
+	if a.lockout != nil {
+		if err := a.lockout.check(r, r.Header.Get(a.headerNames.Credential)); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := a.verify(r.Header, &r.Body, func(headers map[string]string) string {
+		return CreateCanonicalRequestString(r.Method, r.Host, r.URL.Path, r.URL.RawQuery, headers)
+	}, event)
+	if err != nil {
+		var validationErr *ValidationError
+		if a.lockout != nil && errors.As(err, &validationErr) {
+			a.lockout.fail(r, r.Header.Get(a.headerNames.Credential), validationErr.Reason)
+		}
+		return nil, err
+	}
+
+	if result.policy != nil {
+		if err := result.policy.Allow(r, a.clock()); err != nil {
+			return nil, &ValidationError{
+				Code:    http.StatusForbidden,
+				Reason:  ReasonForbiddenByPolicy,
+				Message: "Forbidden by policy: " + err.Error(),
+			}
+		}
+	}
+
+	if a.nonceStore != nil {
+		nonceStoreStart := time.Now()
+		nonce := result.Nonce
+		seen := a.nonceStore.Seen(nonce)
+		if !seen {
+			a.nonceStore.Store(nonce)
+		}
+		event.NonceStoreDuration = time.Since(nonceStoreStart)
+		if seen {
+			return nil, &ValidationError{
+				Code:    http.StatusForbidden,
+				Reason:  ReasonReplayedNonce,
+				Message: "Nonce already used",
+			}
+		}
+	}
+
+	if a.rateLimiter != nil {
+		if err := a.rateLimit(r, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// verify checks the signature of a request or response with the given
+// header and body. canonical returns the canonical string of the message for
+// the signed header values.
+func (a *Authenticator) verify(header http.Header, body *io.ReadCloser, canonical func(headers map[string]string) string, event *ValidationEvent) (*Result, error) {
+	header = a.headerNames.toDefault(header)
 	for _, h := range requiredHeaders {
-		if r.Header.Get(h) == "" {
-			return false, &ValidationError{
+		if header.Get(h) == "" {
+			return nil, &ValidationError{
 				Code:    http.StatusUnprocessableEntity,
+				Reason:  ReasonMissingHeader,
 				Message: fmt.Sprintf("%s is a required header", h),
 			}
 		}
 	}
 
-	timestamp, err := strconv.ParseInt(r.Header.Get("X-Timestamp"), 10, 64)
+	timestamp, err := strconv.ParseInt(header.Get("X-Timestamp"), 10, 64)
 	if err != nil {
-		return false, &ValidationError{
+		return nil, &ValidationError{
 			Code:    http.StatusBadRequest,
+			Reason:  ReasonInvalidTimestamp,
 			Message: "Invalid timestamp",
 		}
 	}
 
-	requestTime := time.Now().Unix()
+	requestTime := a.clock().Unix()
+	event.Skew = time.Duration(timestamp-requestTime) * time.Second
 	if timestamp < requestTime-a.timeTolerance || timestamp > requestTime+a.timeTolerance {
-		return false, &ValidationError{
+		return nil, &ValidationError{
 			Code:    http.StatusBadRequest,
+			Reason:  ReasonTimestampOutOfBounds,
 			Message: "Timestamp out of bounds",
 		}
 	}
 
-	if a.public != r.Header.Get("Credential") {
-		return false, &ValidationError{
+	credential, ok := a.credential(header.Get("Credential"))
+	if !ok {
+		return nil, &ValidationError{
 			Code:    http.StatusForbidden,
+			Reason:  ReasonUnknownCredential,
 			Message: "Not authorized",
 		}
 	}
 
+	if err := a.checkScope(header.Get("X-Scope"), timestamp, credential); err != nil {
+		return nil, err
+	}
+
 	var content []byte
-	if r.Body != nil {
-		content, err = io.ReadAll(r.Body)
+	if *body != nil {
+		content, err = io.ReadAll(*body)
 		if err != nil {
-			return false, &ValidationError{
+			return nil, &ValidationError{
 				Code:    http.StatusBadRequest,
+				Reason:  ReasonUnreadableBody,
 				Message: "Unable to read request body",
 			}
 		}
-		r.Body = io.NopCloser(bytes.NewReader(content))
+		*body = io.NopCloser(bytes.NewReader(content))
 	}
+	event.BodySize = len(content)
 
-	if len(content) > 0 && r.Header.Get("X-Content-SHA256") == "" {
-		return false, &ValidationError{
+	if len(content) > 0 && header.Get("X-Content-SHA256") == "" {
+		return nil, &ValidationError{
 			Code:    http.StatusUnprocessableEntity,
+			Reason:  ReasonMissingContentHash,
 			Message: "X-Content-SHA256 header is required with content",
 		}
 	}
 	if len(content) > 0 {
 		contentHash := sha256.Sum256(content)
 		expected := base64.StdEncoding.EncodeToString(contentHash[:])
-		if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Content-SHA256"))) {
-			return false, &ValidationError{
+		if !hmac.Equal([]byte(expected), []byte(header.Get("X-Content-SHA256"))) {
+			return nil, &ValidationError{
 				Code:    http.StatusBadRequest,
+				Reason:  ReasonInvalidContentHash,
 				Message: "Invalid content hash",
 			}
 		}
@@ -151,32 +472,82 @@ func (a *Authenticator) Validate(r *http.Request) (bool, error) {
 
 	headers := make(map[string]string)
 	headers["X-Timestamp"] = strconv.FormatInt(timestamp, 10)
-	headers["X-Nonce"] = r.Header.Get("X-Nonce")
-	if r.Header.Get("X-Content-SHA256") != "" {
-		headers["X-Content-SHA256"] = r.Header.Get("X-Content-SHA256")
+	headers["X-Nonce"] = header.Get("X-Nonce")
+	if header.Get("X-Content-SHA256") != "" {
+		headers["X-Content-SHA256"] = header.Get("X-Content-SHA256")
 	}
+	if header.Get("X-Scope") != "" {
+		headers["X-Scope"] = header.Get("X-Scope")
+	}
+	addSignedHeaders(headers, header)
 
-	canonicalRequest := CreateCanonicalRequestString(r.Method, r.Host, r.URL.Path, r.URL.RawQuery, headers)
+	canonicalRequest := canonical(headers)
 
-	signature := CreateSignature(canonicalRequest, timestamp, string(a.private))
+	var valid bool
+	if credential.Verifier != nil {
+		valid, err = credential.Verifier.Verify(canonicalRequest, timestamp, headers["X-Scope"], header.Get("Signature"))
+		if err != nil {
+			return nil, &ValidationError{
+				Code:    http.StatusInternalServerError,
+				Reason:  ReasonVerifierFailed,
+				Message: "Unable to verify signature",
+			}
+		}
+	} else {
+		signature := createSignature(canonicalRequest, timestamp, credential.Private, credential.Scope, headers["X-Scope"])
+		valid = hmac.Equal([]byte(signature), []byte(header.Get("Signature")))
+	}
 
-	if !hmac.Equal([]byte(signature), []byte(r.Header.Get("Signature"))) {
-		return false, &ValidationError{
+	if !valid {
+		validationErr := &ValidationError{
 			Code:    http.StatusForbidden,
+			Reason:  ReasonInvalidSignature,
 			Message: "Not authorized",
 		}
+		if a.debug || credential.Debug {
+			validationErr.CanonicalRequest = canonicalRequest
+			validationErr.StringToSign = CreateStringToSign(canonicalRequest, timestamp)
+		}
+		return nil, validationErr
 	}
 
-	if a.nonceStore != nil {
-		nonce := r.Header.Get("X-Nonce")
-		if a.nonceStore.Seen(nonce) {
-			return false, &ValidationError{
-				Code:    http.StatusForbidden,
-				Message: "Nonce already used",
-			}
-		}
-		a.nonceStore.Store(nonce)
+	// Only a valid signature proves that the request comes from the
+	// credential, so that callers cannot create metric labels by naming
+	// credentials of a store that accepts any ID, such as a KeyDeriver.
+	event.Credential = credential.ID
+
+	if err := a.checkStatus(credential, a.clock()); err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Credential:    credential.ID,
+		Metadata:      credential.Metadata,
+		Algorithm:     header.Get("Authorization"),
+		Timestamp:     time.Unix(timestamp, 0),
+		Nonce:         headers["X-Nonce"],
+		SignedHeaders: headers,
+		ContentHash:   headers["X-Content-SHA256"],
+		policy:        credential.Policy,
+		rateLimit:     credential.RateLimit,
+	}, nil
+}
+
+// credential returns the credential with the given ID, checking the key pair
+// passed to NewAuthenticator before the credential store.
+func (a *Authenticator) credential(id string) (*Credential, bool) {
+	if id == a.public {
+		return &Credential{ID: a.public, Private: a.private.Bytes(), Verifier: a.verifier}, true
+	}
+
+	if a.credentials == nil {
+		return nil, false
+	}
+
+	credential, ok := a.credentials.Credential(id)
+	if !ok || credential == nil || (len(credential.Private) == 0 && credential.Verifier == nil) {
+		return nil, false
 	}
 
-	return true, nil
+	return credential, true
 }
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -83,10 +86,22 @@ func TestThatNewAuthenticatorReturnsErrorEmptyPrivateKey(t *testing.T) {
 	}
 }
 
+func TestThatNewAuthenticatorReturnsErrorShortPrivateKey(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+
+	for _, privateKey := range []string{"true", GenerateSecureRandom(15)} {
+		authenticator, err := NewAuthenticator(publicKey, privateKey, 300)
+
+		if authenticator != nil || err == nil || err.Error() != "private key must be at least 16 bytes" {
+			t.Fatalf("unexpected result for %q: %v", privateKey, err)
+		}
+	}
+}
+
 func TestThatNewAuthenticatorReturnsErrorMalformedPrivateKey(t *testing.T) {
 	errMsg := "malformed private key"
 	publicKey := GenerateSecureRandom(16)
-	privateKey := "true"
+	privateKey := "not a key!"
 
 	authenticator, err := NewAuthenticator(publicKey, privateKey, 300)
 
@@ -353,3 +368,205 @@ func TestThatValidateDoesNotStoreNonceOfInvalidRequest(t *testing.T) {
 		t.Fatalf("valid request rejected after forged attempt: %v", err)
 	}
 }
+
+func TestThatAuthenticateReturnsResultValidRequest(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	result, err := authenticator.Authenticate(signedRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Credential != publicKey {
+		t.Fatalf("expected credential %q, got %q", publicKey, result.Credential)
+	}
+	if result.Algorithm != "HMAC-SHA256" {
+		t.Fatalf("expected algorithm HMAC-SHA256, got %q", result.Algorithm)
+	}
+	if result.Nonce != signedRequest.Header.Get("X-Nonce") {
+		t.Fatalf("expected nonce %q, got %q", signedRequest.Header.Get("X-Nonce"), result.Nonce)
+	}
+	if strconv.FormatInt(result.Timestamp.Unix(), 10) != signedRequest.Header.Get("X-Timestamp") {
+		t.Fatalf("expected timestamp %s, got %d", signedRequest.Header.Get("X-Timestamp"), result.Timestamp.Unix())
+	}
+	if result.ContentHash == "" || result.ContentHash != result.SignedHeaders["X-Content-SHA256"] {
+		t.Fatalf("expected verified content hash, got %q", result.ContentHash)
+	}
+}
+
+func TestThatAuthenticateReturnsNilResultInvalidRequest(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	signedRequest.Header.Set("Signature", "invalid signature")
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	result, err := authenticator.Authenticate(signedRequest)
+
+	if result != nil {
+		t.Fatal("expected nil result")
+	}
+	assertValidationError(t, err, "Not authorized")
+}
+
+type memoryCredentialStore map[string]*Credential
+
+func (s memoryCredentialStore) Credential(id string) (*Credential, bool) {
+	credential, ok := s[id]
+	return credential, ok
+}
+
+func TestThatAuthenticateAcceptsCredentialFromStore(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	clientPublicKey := GenerateSecureRandom(16)
+	clientPrivateKey := GenerateSecureRandom(16)
+
+	credential, err := NewCredential(clientPublicKey, clientPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential.Metadata = map[string]string{"tenant": "acme"}
+
+	signedRequest := signedTestRequest(t, clientPublicKey, clientPrivateKey)
+
+	store := memoryCredentialStore{clientPublicKey: credential}
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithCredentialStore(store))
+	result, err := authenticator.Authenticate(signedRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Credential != clientPublicKey || result.Metadata["tenant"] != "acme" {
+		t.Fatalf("expected credential %q with metadata, got %+v", clientPublicKey, result)
+	}
+}
+
+func TestThatAuthenticateRejectsCredentialMissingFromStore(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, GenerateSecureRandom(16), privateKey)
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithCredentialStore(memoryCredentialStore{}))
+	_, err := authenticator.Authenticate(signedRequest)
+
+	assertValidationError(t, err, "Not authorized")
+}
+
+func TestThatValidateOmitsDebugDetailsByDefault(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	signedRequest.Header.Set("Signature", "invalid signature")
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	_, err := authenticator.Validate(signedRequest)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+	if validationErr.CanonicalRequest != "" || validationErr.StringToSign != "" {
+		t.Fatal("expected no debug details without debug mode")
+	}
+}
+
+func TestThatValidateReportsDebugDetailsWithDebug(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	signedRequest.Header.Set("Signature", "invalid signature")
+
+	explanation, _ := Explain(signedRequest, privateKey)
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithDebug())
+	_, err := authenticator.Validate(signedRequest)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+	if validationErr.CanonicalRequest != explanation.CanonicalRequest {
+		t.Fatalf("expected canonical request %q, got %q", explanation.CanonicalRequest, validationErr.CanonicalRequest)
+	}
+	if validationErr.StringToSign != explanation.StringToSign {
+		t.Fatalf("expected string to sign %q, got %q", explanation.StringToSign, validationErr.StringToSign)
+	}
+}
+
+func TestThatValidateReportsDebugDetailsForDebugCredential(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	clientPublicKey := GenerateSecureRandom(16)
+	clientPrivateKey := GenerateSecureRandom(16)
+
+	credential, _ := NewCredential(clientPublicKey, clientPrivateKey)
+	credential.Debug = true
+
+	signedRequest := signedTestRequest(t, clientPublicKey, clientPrivateKey)
+	signedRequest.Header.Set("Signature", "invalid signature")
+
+	store := memoryCredentialStore{clientPublicKey: credential}
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithCredentialStore(store))
+	_, err := authenticator.Validate(signedRequest)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+	if validationErr.CanonicalRequest == "" || validationErr.StringToSign == "" {
+		t.Fatal("expected debug details for debug credential")
+	}
+}
+
+func TestThatLoggerRecordsRejectedRequestWithoutSecrets(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	signature := signedRequest.Header.Get("Signature")
+	signedRequest.Header.Set("X-Nonce", "tampered")
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithLogger(logger))
+	authenticator.Validate(signedRequest)
+	logger.Info("authenticator", "authenticator", authenticator)
+
+	output := buf.String()
+	if !strings.Contains(output, `"reason":"invalid_signature"`) || !strings.Contains(output, `"level":"WARN"`) {
+		t.Fatalf("expected warning with reason, got %s", output)
+	}
+	if !strings.Contains(output, `"credential":"`+publicKey+`"`) {
+		t.Fatalf("expected credential in log, got %s", output)
+	}
+	if strings.Contains(output, privateKey) || strings.Contains(output, signature) {
+		t.Fatalf("expected no private key or signature in log, got %s", output)
+	}
+}
+
+func TestThatCredentialFormattingOmitsPrivateKey(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	credential, _ := NewCredential(publicKey, privateKey)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	logger.Info("credential", "credential", credential)
+	fmt.Fprintf(&buf, "%v %s %+v", credential, credential, credential)
+
+	decodedPrivateKey, _ := hex.DecodeString(privateKey)
+	if strings.Contains(buf.String(), privateKey) || strings.Contains(buf.String(), string(decodedPrivateKey)) {
+		t.Fatalf("expected no private key in output, got %s", buf.String())
+	}
+}
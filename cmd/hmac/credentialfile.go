@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+// fileKeyFlags selects the key of an encrypted credential file.
+type fileKeyFlags struct {
+	passphraseFile string
+	keyFile        string
+	env            string
+}
+
+func (f *fileKeyFlags) register(fs *flag.FlagSet, prefix string, env string) {
+	fs.StringVar(&f.passphraseFile, prefix+"passphrase-file", "", "read the "+strings.TrimSuffix(prefix, "-")+" passphrase from `file` (default $"+env+")")
+	fs.StringVar(&f.keyFile, prefix+"key-file", "", "read the "+strings.TrimSuffix(prefix, "-")+" hex encoded 32-byte master key from `file`")
+	f.env = env
+}
+
+func (f *fileKeyFlags) resolve() (hmac.FileKey, error) {
+	switch {
+	case f.keyFile != "":
+		data, err := os.ReadFile(f.keyFile)
+		if err != nil {
+			return hmac.FileKey{}, err
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return hmac.FileKey{}, fmt.Errorf("malformed master key in %s", f.keyFile)
+		}
+		return hmac.MasterFileKey(key), nil
+	case f.passphraseFile != "":
+		data, err := os.ReadFile(f.passphraseFile)
+		if err != nil {
+			return hmac.FileKey{}, err
+		}
+		return hmac.PassphraseFileKey(strings.TrimRight(string(data), "\r\n")), nil
+	case os.Getenv(f.env) != "":
+		return hmac.PassphraseFileKey(os.Getenv(f.env)), nil
+	default:
+		return hmac.FileKey{}, fmt.Errorf("passphrase or master key required")
+	}
+}
+
+func runCredentials(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	usage := func() {
+		fmt.Fprintln(stderr, "Usage: hmac credentials <encrypt|decrypt|rekey> [flags]")
+		fmt.Fprintln(stderr, "\n  encrypt  encrypt a credentials file")
+		fmt.Fprintln(stderr, "  decrypt  decrypt an encrypted credentials file")
+		fmt.Fprintln(stderr, "  rekey    change the key of an encrypted credentials file")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	command := args[0]
+	if command != "encrypt" && command != "decrypt" && command != "rekey" {
+		usage()
+		return 2
+	}
+
+	fs := flag.NewFlagSet("credentials "+command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	in := fs.String("in", "-", "read the file from `file`, - for stdin")
+	out := fs.String("o", "", "write the result to `file` instead of stdout")
+	var key, newKey fileKeyFlags
+	key.register(fs, "", "HMAC_CREDENTIALS_PASSPHRASE")
+	if command == "rekey" {
+		newKey.register(fs, "new-", "HMAC_CREDENTIALS_NEW_PASSPHRASE")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	fileKey, err := key.resolve()
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac credentials %s: %v\n", command, err)
+		return 2
+	}
+
+	var data []byte
+	if *in == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(*in)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac credentials %s: %v\n", command, err)
+		return 1
+	}
+
+	var result []byte
+	switch command {
+	case "encrypt":
+		result, err = hmac.EncryptCredentialFile(data, fileKey)
+	case "decrypt":
+		result, err = hmac.DecryptCredentialFile(data, fileKey)
+	case "rekey":
+		var newFileKey hmac.FileKey
+		newFileKey, err = newKey.resolve()
+		if err == nil {
+			result, err = hmac.RekeyCredentialFile(data, fileKey, newFileKey)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac credentials %s: %v\n", command, err)
+		return 1
+	}
+
+	if *out == "" {
+		stdout.Write(result)
+		return 0
+	}
+	if err := os.WriteFile(*out, result, 0600); err != nil {
+		fmt.Fprintf(stderr, "hmac credentials %s: %v\n", command, err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestThatCredentialsEncryptDecryptAndRekeyRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	plaintext := `{"credentials": [{"id": "client-1", "secret": "00112233445566778899aabbccddeeff"}]}`
+	encrypted := filepath.Join(dir, "credentials.json.enc")
+	keyFile := filepath.Join(dir, "master.key")
+	os.WriteFile(keyFile, []byte(strings.Repeat("ab", 32)+"\n"), 0600)
+	t.Setenv("HMAC_CREDENTIALS_PASSPHRASE", "correct horse")
+
+	if code, _, stderr := runCommand(t, plaintext, "credentials", "encrypt", "-o", encrypted); code != 0 {
+		t.Fatalf("credentials encrypt failed: %s", stderr)
+	}
+
+	code, stdout, stderr := runCommand(t, "", "credentials", "rekey", "-in", encrypted, "-new-key-file", keyFile)
+	if code != 0 {
+		t.Fatalf("credentials rekey failed: %s", stderr)
+	}
+	os.WriteFile(encrypted, []byte(stdout), 0600)
+
+	if code, _, _ := runCommand(t, "", "credentials", "decrypt", "-in", encrypted); code == 0 {
+		t.Fatal("expected decrypt with old passphrase to fail")
+	}
+	code, stdout, stderr = runCommand(t, "", "credentials", "decrypt", "-in", encrypted, "-key-file", keyFile)
+	if code != 0 || stdout != plaintext {
+		t.Fatalf("unexpected decrypt output %q: %s", stdout, stderr)
+	}
+}
+
+func TestThatCredentialsRequiresKey(t *testing.T) {
+	t.Setenv("HMAC_CREDENTIALS_PASSPHRASE", "")
+
+	if code, _, _ := runCommand(t, "{}", "credentials", "encrypt"); code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+// credentialMap is a CredentialStore of the credentials given on the
+// command line.
+type credentialMap map[string]*hmac.Credential
+
+func (m credentialMap) Credential(id string) (*hmac.Credential, bool) {
+	credential, ok := m[id]
+	return credential, ok
+}
+
+// credentialListFlag collects repeated -credential public:private flags.
+type credentialListFlag []*hmac.Credential
+
+func (c *credentialListFlag) String() string {
+	ids := make([]string, len(*c))
+	for i, credential := range *c {
+		ids[i] = credential.ID
+	}
+	return strings.Join(ids, ", ")
+}
+
+func (c *credentialListFlag) Set(value string) error {
+	public, private, ok := strings.Cut(value, ":")
+	if !ok {
+		return fmt.Errorf("credential must have the form public:private")
+	}
+
+	credential, err := hmac.NewCredential(public, private)
+	if err != nil {
+		return err
+	}
+	*c = append(*c, credential)
+
+	return nil
+}
+
+// newAuthenticator returns an Authenticator accepting the credentials
+// selected by credentials and the extra credentials. The first credential is
+// returned too, for commands that sign with it.
+func newAuthenticator(credentials *credentialFlags, extra []*hmac.Credential, tolerance int64, options ...hmac.AuthenticatorOption) (*hmac.Authenticator, *hmac.Credential, error) {
+	if _, err := credentials.resolve(); err != nil {
+		return nil, nil, err
+	}
+
+	all := extra
+	if keys := credentials.keys; keys.public != "" || keys.private != "" {
+		credential, err := hmac.NewCredential(keys.public, keys.private)
+		if err != nil {
+			return nil, nil, err
+		}
+		all = append([]*hmac.Credential{credential}, all...)
+	}
+	if len(all) == 0 {
+		return nil, nil, fmt.Errorf("at least one credential is required")
+	}
+
+	primary := all[0]
+	store := make(credentialMap)
+	for _, credential := range all[1:] {
+		store[credential.ID] = credential
+	}
+	options = append(options, hmac.WithCredentialStore(store))
+
+	authenticator, err := hmac.NewAuthenticator(primary.ID, hex.EncodeToString(primary.Private), tolerance, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return authenticator, primary, nil
+}
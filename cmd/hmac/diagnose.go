@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+func runDiagnose(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diagnose", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: hmac diagnose [flags] file")
+		fmt.Fprintln(stderr, "\nfile is a raw HTTP/1.1 request dump, or a HAR export with -har (\"-\" for stdin).")
+		fs.PrintDefaults()
+	}
+	var credentials credentialFlags
+	credentials.register(fs)
+	isHAR := fs.Bool("har", false, "read a HAR export instead of a raw request")
+	at := fs.String("at", "", "validate at this RFC 3339 `time` instead of the recorded time")
+	tolerance := fs.Int64("tolerance", 300, "allowed clock skew in seconds")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	if _, err := credentials.resolve(); err != nil {
+		fmt.Fprintf(stderr, "hmac diagnose: %v\n", err)
+		return 2
+	}
+
+	var atTime time.Time
+	if *at != "" {
+		var err error
+		if atTime, err = time.Parse(time.RFC3339, *at); err != nil {
+			fmt.Fprintf(stderr, "hmac diagnose: invalid -at: %v\n", err)
+			return 2
+		}
+	}
+
+	authenticator, err := hmac.NewAuthenticator(credentials.keys.public, credentials.keys.private, *tolerance)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac diagnose: %v\n", err)
+		return 2
+	}
+
+	recorded, err := readRecorded(fs.Arg(0), *isHAR, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac diagnose: %v\n", err)
+		return 1
+	}
+
+	code := 0
+	for i, r := range recorded {
+		when := r.Time
+		note := "recorded time"
+		switch {
+		case !atTime.IsZero():
+			when, note = atTime, "-at"
+		case when.IsZero():
+			timestamp, _ := strconv.ParseInt(r.Request.Header.Get("X-Timestamp"), 10, 64)
+			when, note = time.Unix(timestamp, 0), "recorded time unknown, using X-Timestamp"
+		}
+
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintf(stdout, "request %d: %s %s%s at %s (%s)\n", i+1, r.Request.Method, r.Request.Host, r.Request.URL.RequestURI(), when.UTC().Format(time.RFC3339), note)
+
+		diagnosis := authenticator.Diagnose(r.Request, when)
+		for _, step := range diagnosis.Steps {
+			status := "ok"
+			if !step.OK {
+				status = "FAIL"
+			}
+			fmt.Fprintf(stdout, "  %-5s %s: %s\n", status, step.Name, step.Detail)
+		}
+
+		if diagnosis.OK() {
+			fmt.Fprintf(stdout, "valid: signed by %s\n", diagnosis.Result.Credential)
+			continue
+		}
+		code = 1
+		var validationErr *hmac.ValidationError
+		if errors.As(diagnosis.Err, &validationErr) {
+			fmt.Fprintf(stdout, "invalid: %s (%s)\n", validationErr.Message, validationErr.Reason)
+		} else {
+			fmt.Fprintf(stdout, "invalid: %v\n", diagnosis.Err)
+		}
+	}
+
+	return code
+}
+
+func readRecorded(file string, isHAR bool, stdin io.Reader) ([]*hmac.RecordedRequest, error) {
+	r := stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if isHAR {
+		return hmac.ReadHAR(r)
+	}
+
+	recorded, err := hmac.ReadRequestDump(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*hmac.RecordedRequest{recorded}, nil
+}
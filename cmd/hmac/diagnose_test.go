@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"testing"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+func TestThatDiagnoseReportsSteps(t *testing.T) {
+	public, private := testKeys(t)
+
+	request, _ := http.NewRequest(http.MethodPost, "http://localhost:8080/items", strings.NewReader("payload"))
+	requestService, _ := hmac.NewRequestService(public, private)
+	requestService.SignRequest(request)
+	dump, _ := httputil.DumpRequestOut(request, true)
+
+	code, stdout, stderr := runCommand(t, string(dump), "diagnose", "-public", public, "-private", private, "-")
+	if code != 0 {
+		t.Fatalf("diagnose failed: %s%s", stdout, stderr)
+	}
+	if !strings.Contains(stdout, "ok    signature: ") || !strings.Contains(stdout, "valid: signed by "+public) {
+		t.Fatalf("unexpected output %q", stdout)
+	}
+
+	tampered := bytes.Replace(dump, []byte("/items"), []byte("/other"), 1)
+	code, stdout, _ = runCommand(t, string(tampered), "diagnose", "-public", public, "-private", private, "-")
+	if code != 1 || !strings.Contains(stdout, "FAIL  signature: ") {
+		t.Fatalf("expected signature failure, got %d %q", code, stdout)
+	}
+}
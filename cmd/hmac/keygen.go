@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+func runKeygen(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	length := fs.Int("length", 16, "key length in bytes")
+	asJSON := fs.Bool("json", false, "print the key pair as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *length < 16 {
+		fmt.Fprintln(stderr, "hmac keygen: length must be at least 16 bytes")
+		return 2
+	}
+
+	public := hmac.GenerateSecureRandom(*length)
+	private := hmac.GenerateSecureRandom(*length)
+
+	if *asJSON {
+		json.NewEncoder(stdout).Encode(map[string]string{
+			"public":  public,
+			"private": private,
+		})
+		return 0
+	}
+
+	fmt.Fprintf(stdout, "public:  %s\nprivate: %s\n", public, private)
+	return 0
+}
@@ -0,0 +1,85 @@
+// Command hmac generates keys and signs and verifies requests with the
+// github.com/pascalallen/hmac/v2 package.
+//
+// Usage:
+//
+//	hmac <command> [flags]
+//
+// The commands are:
+//
+//	keygen       generate a public/private key pair
+//	sign         sign a request and print its headers
+//	verify       verify a signed request
+//	send         sign and send a request, curl-style
+//	diagnose     diagnose a captured request dump or HAR export
+//	serve        run a mock server that reports how requests validate
+//	sidecar      validate requests and forward them to an upstream
+//	proxy        run a local forward proxy that signs requests
+//	vectors      generate and run the conformance test vectors
+//	credentials  encrypt, decrypt and re-key credentials files
+//
+// Keys are read from the -public and -private flags, then from the
+// HMAC_PUBLIC_KEY and HMAC_PRIVATE_KEY environment variables, then from a
+// profile in the JSON file named by -profiles or $HMAC_PROFILES (by default
+// hmac/profiles.json in the user configuration directory).
+//
+// Run "hmac <command> -h" for the flags of a command.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int
+}
+
+var commands = []command{
+	{"keygen", "generate a public/private key pair", runKeygen},
+	{"sign", "sign a request and print its headers", runSign},
+	{"verify", "verify a signed request", runVerify},
+	{"send", "sign and send a request, curl-style", runSend},
+	{"diagnose", "diagnose a captured request dump or HAR export", runDiagnose},
+	{"serve", "run a mock server that reports how requests validate", runServe},
+	{"sidecar", "validate requests and forward them to an upstream", runSidecar},
+	{"proxy", "run a local forward proxy that signs requests", runProxy},
+	{"vectors", "generate and run the conformance test vectors", runVectors},
+	{"credentials", "encrypt, decrypt and re-key credentials files", runCredentials},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], stdin, stdout, stderr)
+		}
+	}
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "hmac: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: hmac <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-13s%s\n", c.name, c.usage)
+	}
+}
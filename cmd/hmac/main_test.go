@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCommand(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func testKeys(t *testing.T) (string, string) {
+	t.Helper()
+
+	code, stdout, stderr := runCommand(t, "", "keygen", "-json")
+	if code != 0 {
+		t.Fatalf("keygen failed: %s", stderr)
+	}
+
+	var keys map[string]string
+	if err := json.Unmarshal([]byte(stdout), &keys); err != nil {
+		t.Fatal(err)
+	}
+
+	return keys["public"], keys["private"]
+}
+
+func headerArgs(headers string) []string {
+	var args []string
+	for _, line := range strings.Split(strings.TrimSpace(headers), "\n") {
+		args = append(args, "-H", line)
+	}
+	return args
+}
+
+func TestThatKeygenPrintsKeyPair(t *testing.T) {
+	public, private := testKeys(t)
+
+	if len(public) != 32 || len(private) != 32 || public == private {
+		t.Fatalf("unexpected key pair %q, %q", public, private)
+	}
+}
+
+func TestThatSignedRequestVerifies(t *testing.T) {
+	public, private := testKeys(t)
+	bodyFile := filepath.Join(t.TempDir(), "body.json")
+	os.WriteFile(bodyFile, []byte(`{"foo": "bar"}`), 0600)
+
+	code, headers, stderr := runCommand(t, "", "sign", "-public", public, "-private", private,
+		"-X", "POST", "-url", "http://localhost:8080/items?abc=xyz", "-body-file", bodyFile)
+	if code != 0 {
+		t.Fatalf("sign failed: %s", stderr)
+	}
+	if !strings.Contains(headers, "Signature: ") || !strings.Contains(headers, "X-Content-Sha256: ") {
+		t.Fatalf("expected signature headers, got %s", headers)
+	}
+
+	args := append([]string{"verify", "-public", public, "-private", private,
+		"-X", "POST", "-url", "http://localhost:8080/items?abc=xyz", "-body-file", "-"}, headerArgs(headers)...)
+	code, stdout, stderr := runCommand(t, `{"foo": "bar"}`, args...)
+	if code != 0 {
+		t.Fatalf("verify failed: %s%s", stdout, stderr)
+	}
+	if !strings.HasPrefix(stdout, "valid: signed by "+public) {
+		t.Fatalf("unexpected verify output %q", stdout)
+	}
+}
+
+func TestThatVerifyReportsTamperedRequest(t *testing.T) {
+	public, private := testKeys(t)
+
+	_, headers, _ := runCommand(t, "", "sign", "-public", public, "-private", private, "-url", "http://localhost:8080/items")
+
+	args := append([]string{"verify", "-debug", "-public", public, "-private", private,
+		"-url", "http://localhost:8080/other"}, headerArgs(headers)...)
+	code, stdout, _ := runCommand(t, "", args...)
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stdout, "invalid_signature") || !strings.Contains(stdout, "GET localhost:8080/other") {
+		t.Fatalf("unexpected verify output %q", stdout)
+	}
+}
+
+func TestThatUnknownCommandFails(t *testing.T) {
+	code, _, stderr := runCommand(t, "", "frobnicate")
+
+	if code != 2 || !strings.Contains(stderr, "unknown command") {
+		t.Fatalf("expected usage error, got %d %q", code, stderr)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// profile is a named set of credentials in the profiles file:
+//
+//	{
+//	  "default": {"public": "...", "private": "..."},
+//	  "staging": {"public": "...", "private": "...", "response_public": "...", "response_private": "..."}
+//	}
+//
+// The response keys verify responses signed by the server.
+type profile struct {
+	Public          string `json:"public"`
+	Private         string `json:"private"`
+	ResponsePublic  string `json:"response_public,omitempty"`
+	ResponsePrivate string `json:"response_private,omitempty"`
+}
+
+// defaultProfilesPath returns $HMAC_PROFILES or hmac/profiles.json in the
+// user configuration directory.
+func defaultProfilesPath() string {
+	if path := os.Getenv("HMAC_PROFILES"); path != "" {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "hmac", "profiles.json")
+}
+
+// errProfileNotFound is returned by loadProfile for a missing profile.
+var errProfileNotFound = errors.New("profile not found")
+
+func loadProfile(path string, name string) (*profile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles map[string]*profile
+	if err := json.Unmarshal(b, &profiles); err != nil {
+		return nil, fmt.Errorf("malformed profiles file %s: %w", path, err)
+	}
+
+	p, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q in %s", errProfileNotFound, name, path)
+	}
+
+	return p, nil
+}
+
+// credentialFlags select the credentials of a command. Keys given as flags
+// take precedence over the HMAC_PUBLIC_KEY and HMAC_PRIVATE_KEY environment
+// variables, which take precedence over the profile. The "default" profile
+// is only used when no -profile is given, if it exists and a key is missing.
+type credentialFlags struct {
+	keys     keyFlags
+	profile  string
+	profiles string
+}
+
+func (f *credentialFlags) register(fs *flag.FlagSet) {
+	f.keys.register(fs)
+	fs.StringVar(&f.profile, "profile", os.Getenv("HMAC_PROFILE"), "credentials profile `name` (default \"default\" when the profiles file exists)")
+	fs.StringVar(&f.profiles, "profiles", defaultProfilesPath(), "profiles `file`")
+}
+
+// resolve fills in the keys not given as flags and returns the profile used,
+// if any.
+func (f *credentialFlags) resolve() (*profile, error) {
+	if f.keys.public == "" {
+		f.keys.public = os.Getenv("HMAC_PUBLIC_KEY")
+	}
+	if f.keys.private == "" {
+		f.keys.private = os.Getenv("HMAC_PRIVATE_KEY")
+	}
+
+	name := f.profile
+	implicit := name == ""
+	if implicit {
+		if f.keys.public != "" && f.keys.private != "" {
+			return nil, nil
+		}
+		if _, err := os.Stat(f.profiles); f.profiles == "" || err != nil {
+			return nil, nil
+		}
+		name = "default"
+	}
+
+	p, err := loadProfile(f.profiles, name)
+	if implicit && errors.Is(err, errProfileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.keys.public == "" {
+		f.keys.public = p.Public
+	}
+	if f.keys.private == "" {
+		f.keys.private = p.Private
+	}
+
+	return p, nil
+}
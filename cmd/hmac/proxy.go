@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+// proxyConfig is the configuration file of the proxy command. Upstreams are
+// reached over HTTPS unless their scheme is "http":
+//
+//	{
+//	  "hosts": {
+//	    "api.example.com": {"public": "...", "private": "..."},
+//	    "localhost:9000": {"public": "...", "private": "...", "scheme": "http"}
+//	  }
+//	}
+type proxyConfig struct {
+	Hosts map[string]struct {
+		Public  string `json:"public"`
+		Private string `json:"private"`
+		Scheme  string `json:"scheme"`
+	} `json:"hosts"`
+}
+
+func loadProxySigners(path string) (map[string]hmac.ProxyUpstream, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config proxyConfig
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("malformed config %s: %w", path, err)
+	}
+
+	signers := make(map[string]hmac.ProxyUpstream, len(config.Hosts))
+	for host, keys := range config.Hosts {
+		if keys.Scheme != "" && keys.Scheme != "http" && keys.Scheme != "https" {
+			return nil, fmt.Errorf("host %s: unsupported scheme %q", host, keys.Scheme)
+		}
+		signer, err := hmac.NewRequestService(keys.Public, keys.Private)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", host, err)
+		}
+		signers[host] = hmac.ProxyUpstream{Signer: signer, Scheme: keys.Scheme}
+	}
+
+	return signers, nil
+}
+
+func runProxy(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("proxy", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", "localhost:8888", "listen `address`")
+	config := fs.String("config", "", "JSON `file` of credentials by upstream host")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *config == "" {
+		fmt.Fprintln(stderr, "hmac proxy: -config is required")
+		return 2
+	}
+
+	signers, err := loadProxySigners(*config)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac proxy: %v\n", err)
+		return 2
+	}
+
+	fmt.Fprintf(stdout, "listening on %s, set HTTP_PROXY=http://%s\n", *addr, *addr)
+	if err := http.ListenAndServe(*addr, hmac.NewSigningProxy(signers)); err != nil {
+		fmt.Fprintf(stderr, "hmac proxy: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
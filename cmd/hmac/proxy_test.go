@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThatLoadProxySignersReadsHosts(t *testing.T) {
+	public, private := testKeys(t)
+	config := filepath.Join(t.TempDir(), "proxy.json")
+	os.WriteFile(config, []byte(`{"hosts": {"api.example.com": {"public": "`+public+`", "private": "`+private+`"}}}`), 0600)
+
+	signers, err := loadProxySigners(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signers["api.example.com"].Signer == nil {
+		t.Fatalf("expected signer for api.example.com, got %v", signers)
+	}
+}
+
+func TestThatLoadProxySignersRejectsInvalidKeys(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "proxy.json")
+	os.WriteFile(config, []byte(`{"hosts": {"api.example.com": {"public": "abc", "private": "not hex"}}}`), 0600)
+
+	if _, err := loadProxySigners(config); err == nil {
+		t.Fatal("expected error for invalid private key")
+	}
+}
+
+func TestThatLoadProxySignersRejectsUnknownSchemes(t *testing.T) {
+	public, private := testKeys(t)
+	config := filepath.Join(t.TempDir(), "proxy.json")
+	os.WriteFile(config, []byte(`{"hosts": {"api.example.com": {"public": "`+public+`", "private": "`+private+`", "scheme": "ftp"}}}`), 0600)
+
+	if _, err := loadProxySigners(config); err == nil {
+		t.Fatal("expected error for unsupported scheme")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// headerFlag collects repeated -H "Name: value" flags.
+type headerFlag []string
+
+func (h *headerFlag) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlag) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("header %q must have the form \"Name: value\"", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// requestFlags describe the request to sign or verify.
+type requestFlags struct {
+	method   string
+	url      string
+	headers  headerFlag
+	bodyFile string
+}
+
+func (f *requestFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.method, "X", http.MethodGet, "request method")
+	fs.StringVar(&f.url, "url", "", "request URL")
+	fs.Var(&f.headers, "H", "request header `Name: value` (repeatable)")
+	fs.StringVar(&f.bodyFile, "body-file", "", "read the request body from `file` (\"-\" for stdin)")
+}
+
+func (f *requestFlags) request(stdin io.Reader) (*http.Request, error) {
+	if f.url == "" {
+		return nil, fmt.Errorf("-url is required")
+	}
+
+	body, err := readBody(f.bodyFile, stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	if len(body) > 0 {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequest(f.method, f.url, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range f.headers {
+		name, value, _ := strings.Cut(h, ":")
+		request.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if host := request.Header.Get("Host"); host != "" {
+		request.Host = host
+		request.Header.Del("Host")
+	}
+
+	return request, nil
+}
+
+func readBody(file string, stdin io.Reader) ([]byte, error) {
+	switch file {
+	case "":
+		return nil, nil
+	case "-":
+		return io.ReadAll(stdin)
+	default:
+		return os.ReadFile(file)
+	}
+}
+
+// keyFlags hold the key pair used to sign or verify.
+type keyFlags struct {
+	public  string
+	private string
+}
+
+func (f *keyFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.public, "public", "", "public key")
+	fs.StringVar(&f.private, "private", "", "private key (hex, base64 or base64url)")
+}
+
+func printHeaders(w io.Writer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(w, "%s: %s\n", name, value)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+func runSend(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: hmac send [flags] [URL]")
+		fs.PrintDefaults()
+	}
+	var credentials credentialFlags
+	credentials.register(fs)
+	var req requestFlags
+	req.register(fs)
+	include := fs.Bool("i", false, "print the response status and headers")
+	timeout := fs.Duration("timeout", 30*time.Second, "request timeout")
+	verifyResponse := fs.Bool("verify-response", false, "verify the signature of the response and print nothing if it is invalid")
+	var responseKeys keyFlags
+	fs.StringVar(&responseKeys.public, "response-public", os.Getenv("HMAC_RESPONSE_PUBLIC_KEY"), "public key of signed responses")
+	fs.StringVar(&responseKeys.private, "response-private", os.Getenv("HMAC_RESPONSE_PRIVATE_KEY"), "private key of signed responses (hex, base64 or base64url)")
+	tolerance := fs.Int64("tolerance", 300, "allowed clock skew of signed responses in seconds")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if req.url == "" && fs.NArg() == 1 {
+		req.url = fs.Arg(0)
+	}
+
+	p, err := credentials.resolve()
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac send: %v\n", err)
+		return 2
+	}
+	if p != nil && responseKeys.public == "" {
+		responseKeys.public = p.ResponsePublic
+	}
+	if p != nil && responseKeys.private == "" {
+		responseKeys.private = p.ResponsePrivate
+	}
+
+	var authenticator *hmac.Authenticator
+	if *verifyResponse {
+		authenticator, err = hmac.NewAuthenticator(responseKeys.public, responseKeys.private, *tolerance)
+		if err != nil {
+			fmt.Fprintf(stderr, "hmac send: response keys: %v\n", err)
+			return 2
+		}
+	}
+
+	request, err := req.request(stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac send: %v\n", err)
+		return 2
+	}
+
+	requestService, err := hmac.NewRequestService(credentials.keys.public, credentials.keys.private)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac send: %v\n", err)
+		return 2
+	}
+
+	signedRequest, err := requestService.SignRequest(request)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac send: %v\n", err)
+		return 1
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	response, err := client.Do(signedRequest)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac send: %v\n", err)
+		return 1
+	}
+	defer response.Body.Close()
+
+	// An unverified response is not printed, so that scripts reading
+	// stdout never get unauthenticated data.
+	if authenticator != nil {
+		if _, err := authenticator.AuthenticateResponse(response); err != nil {
+			var validationErr *hmac.ValidationError
+			if errors.As(err, &validationErr) {
+				fmt.Fprintf(stderr, "hmac send: invalid response signature: %s (%s)\n", validationErr.Message, validationErr.Reason)
+			} else {
+				fmt.Fprintf(stderr, "hmac send: invalid response signature: %v\n", err)
+			}
+			return 1
+		}
+	}
+
+	if *include {
+		fmt.Fprintf(stdout, "%s %s\n", response.Proto, response.Status)
+		printHeaders(stdout, response.Header)
+		fmt.Fprintln(stdout)
+	}
+	if _, err := io.Copy(stdout, response.Body); err != nil {
+		fmt.Fprintf(stderr, "hmac send: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+// signingTestServer validates requests and answers with a signed response.
+func signingTestServer(t *testing.T, public string, private string, responsePublic string, responsePrivate string) *httptest.Server {
+	t.Helper()
+
+	authenticator, _ := hmac.NewAuthenticator(public, private, 300)
+	responseSigner, _ := hmac.NewRequestService(responsePublic, responsePrivate)
+
+	server := httptest.NewServer(authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("hello")),
+			Request:    r,
+		}
+		responseSigner.SignResponse(response)
+		for name, values := range response.Header {
+			w.Header()[name] = values
+		}
+		io.Copy(w, response.Body)
+	})))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestThatSendSignsRequestAndVerifiesResponse(t *testing.T) {
+	public, private := testKeys(t)
+	responsePublic, responsePrivate := testKeys(t)
+	server := signingTestServer(t, public, private, responsePublic, responsePrivate)
+
+	t.Setenv("HMAC_PUBLIC_KEY", public)
+	t.Setenv("HMAC_PRIVATE_KEY", private)
+	t.Setenv("HMAC_RESPONSE_PUBLIC_KEY", responsePublic)
+	t.Setenv("HMAC_RESPONSE_PRIVATE_KEY", responsePrivate)
+
+	code, stdout, stderr := runCommand(t, "payload", "send", "-X", "POST", "-body-file", "-", "-verify-response", "-i", server.URL+"/items")
+	if code != 0 {
+		t.Fatalf("send failed: %s", stderr)
+	}
+	if !strings.HasPrefix(stdout, "HTTP/1.1 200 OK\n") || !strings.HasSuffix(stdout, "\n\nhello") {
+		t.Fatalf("unexpected output %q", stdout)
+	}
+}
+
+func TestThatSendRejectsUnverifiedResponse(t *testing.T) {
+	public, private := testKeys(t)
+	responsePublic, responsePrivate := testKeys(t)
+	server := signingTestServer(t, public, private, responsePublic, responsePrivate)
+
+	_, otherPrivate := testKeys(t)
+	code, stdout, stderr := runCommand(t, "", "send", "-public", public, "-private", private,
+		"-verify-response", "-i", "-response-public", responsePublic, "-response-private", otherPrivate, server.URL)
+	if code != 1 || !strings.Contains(stderr, "invalid response signature") {
+		t.Fatalf("expected response verification failure, got %d %q", code, stderr)
+	}
+	if stdout != "" {
+		t.Fatalf("expected unverified response not to be printed, got %q", stdout)
+	}
+}
+
+func TestThatSendReadsCredentialsFromProfile(t *testing.T) {
+	public, private := testKeys(t)
+	responsePublic, responsePrivate := testKeys(t)
+	server := signingTestServer(t, public, private, responsePublic, responsePrivate)
+
+	profiles := filepath.Join(t.TempDir(), "profiles.json")
+	os.WriteFile(profiles, []byte(`{"test": {"public": "`+public+`", "private": "`+private+`"}}`), 0600)
+	t.Setenv("HMAC_PUBLIC_KEY", "")
+	t.Setenv("HMAC_PRIVATE_KEY", "")
+
+	code, stdout, stderr := runCommand(t, "", "send", "-profiles", profiles, "-profile", "test", server.URL)
+	if code != 0 || stdout != "hello" {
+		t.Fatalf("send failed: %d %q %q", code, stdout, stderr)
+	}
+}
+
+func TestThatSendSkipsMissingDefaultProfile(t *testing.T) {
+	public, private := testKeys(t)
+	responsePublic, responsePrivate := testKeys(t)
+	server := signingTestServer(t, public, private, responsePublic, responsePrivate)
+
+	profiles := filepath.Join(t.TempDir(), "profiles.json")
+	os.WriteFile(profiles, []byte(`{"staging": {"public": "`+public+`", "private": "`+private+`"}}`), 0600)
+	t.Setenv("HMAC_PROFILE", "")
+
+	t.Setenv("HMAC_PUBLIC_KEY", public)
+	t.Setenv("HMAC_PRIVATE_KEY", private)
+	code, stdout, stderr := runCommand(t, "", "send", "-profiles", profiles, server.URL)
+	if code != 0 || stdout != "hello" {
+		t.Fatalf("send with keys from the environment failed: %d %q %q", code, stdout, stderr)
+	}
+
+	t.Setenv("HMAC_PRIVATE_KEY", "")
+	code, _, stderr = runCommand(t, "", "send", "-profiles", profiles, server.URL)
+	if code != 2 || strings.Contains(stderr, "profile") {
+		t.Fatalf("expected missing key error without a profile error, got %d %q", code, stderr)
+	}
+
+	code, _, stderr = runCommand(t, "", "send", "-profiles", profiles, "-profile", "production", server.URL)
+	if code != 2 || !strings.Contains(stderr, `"production"`) {
+		t.Fatalf("expected missing profile error, got %d %q", code, stderr)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+type serveReport struct {
+	Received         receivedRequest      `json:"received"`
+	CanonicalRequest string               `json:"canonical_request"`
+	StringToSign     string               `json:"string_to_sign,omitempty"`
+	Valid            bool                 `json:"valid"`
+	Credential       string               `json:"credential,omitempty"`
+	Reason           hmac.Reason          `json:"reason,omitempty"`
+	Message          string               `json:"message,omitempty"`
+	Steps            []hmac.DiagnosisStep `json:"steps"`
+}
+
+type receivedRequest struct {
+	Method  string              `json:"method"`
+	Host    string              `json:"host"`
+	Path    string              `json:"path"`
+	Query   string              `json:"query"`
+	Headers map[string][]string `json:"headers"`
+	Body    string              `json:"body"`
+}
+
+// newServeHandler returns a handler that answers every request with a JSON
+// report of what it received and how it validated.
+func newServeHandler(authenticator *hmac.Authenticator, clock func() time.Time) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+		report := serveReport{
+			Received: receivedRequest{
+				Method:  r.Method,
+				Host:    r.Host,
+				Path:    r.URL.Path,
+				Query:   r.URL.RawQuery,
+				Headers: r.Header,
+				Body:    string(body),
+			},
+		}
+
+		diagnosis := authenticator.Diagnose(r, clock())
+		report.Steps = servedSteps(diagnosis.Steps)
+		if e := diagnosis.Explanation; e != nil {
+			report.CanonicalRequest = e.CanonicalRequest
+			report.StringToSign = e.StringToSign
+		} else {
+			report.CanonicalRequest = canonicalRequest(r)
+		}
+
+		status := http.StatusOK
+		if diagnosis.OK() {
+			report.Valid = true
+			report.Credential = diagnosis.Result.Credential
+		} else {
+			status = http.StatusInternalServerError
+			var validationErr *hmac.ValidationError
+			if errors.As(diagnosis.Err, &validationErr) {
+				status = validationErr.Code
+				report.Reason = validationErr.Reason
+				report.Message = validationErr.Message
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	})
+}
+
+// servedSteps returns steps without the expected signature, so that the
+// server cannot be used to sign requests for its credentials.
+func servedSteps(steps []hmac.DiagnosisStep) []hmac.DiagnosisStep {
+	served := make([]hmac.DiagnosisStep, len(steps))
+	for i, step := range steps {
+		if step.Name == "signature" {
+			step.Detail = "does not match"
+			if step.OK {
+				step.Detail = "matches"
+			}
+		}
+		served[i] = step
+	}
+
+	return served
+}
+
+// canonicalRequest returns the canonical request of r without checking its
+// credential, for requests whose credential is unknown.
+func canonicalRequest(r *http.Request) string {
+	headers := map[string]string{
+		"X-Timestamp": r.Header.Get("X-Timestamp"),
+		"X-Nonce":     r.Header.Get("X-Nonce"),
+	}
+	if timestamp, err := strconv.ParseInt(r.Header.Get("X-Timestamp"), 10, 64); err == nil {
+		headers["X-Timestamp"] = strconv.FormatInt(timestamp, 10)
+	}
+	if contentHash := r.Header.Get("X-Content-SHA256"); contentHash != "" {
+		headers["X-Content-SHA256"] = contentHash
+	}
+
+	return hmac.CreateCanonicalRequestString(r.Method, r.Host, r.URL.Path, r.URL.RawQuery, headers)
+}
+
+func runServe(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", "localhost:8080", "listen `address`")
+	var credentials credentialFlags
+	credentials.register(fs)
+	var extra credentialListFlag
+	fs.Var(&extra, "credential", "additional test credential `public:private` (repeatable)")
+	tolerance := fs.Int64("tolerance", 300, "allowed clock skew in seconds")
+	signResponses := fs.Bool("sign-responses", false, "sign responses with the response keys")
+	var responseKeys keyFlags
+	fs.StringVar(&responseKeys.public, "response-public", "", "public key used to sign responses (default: generated)")
+	fs.StringVar(&responseKeys.private, "response-private", "", "private key used to sign responses, hex, base64 or base64url (default: generated)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	authenticator, _, err := newAuthenticator(&credentials, extra, *tolerance)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac serve: %v\n", err)
+		return 2
+	}
+
+	handler := newServeHandler(authenticator, time.Now)
+	if *signResponses {
+		requestService, err := newResponseSigner(responseKeys, stdout)
+		if err != nil {
+			fmt.Fprintf(stderr, "hmac serve: response keys: %v\n", err)
+			return 2
+		}
+		handler = requestService.SigningMiddleware(handler)
+	}
+
+	fmt.Fprintf(stdout, "listening on %s\n", *addr)
+	if err := http.ListenAndServe(*addr, handler); err != nil {
+		fmt.Fprintf(stderr, "hmac serve: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// newResponseSigner returns the RequestService signing responses with keys.
+// Without keys, it generates a key pair and prints it to stdout for clients
+// to verify responses with, rather than signing responses with a key that
+// also signs requests.
+func newResponseSigner(keys keyFlags, stdout io.Writer) (*hmac.RequestService, error) {
+	if keys.public == "" && keys.private == "" {
+		keys.public = hmac.GenerateSecureRandom(16)
+		keys.private = hmac.GenerateSecureRandom(16)
+		fmt.Fprintf(stdout, "signing responses with public key %s and private key %s\n", keys.public, keys.private)
+	}
+
+	return hmac.NewRequestService(keys.public, keys.private)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+func serveTestRequest(t *testing.T, handler http.Handler, public string, private string) (int, serveReport) {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/items?abc=xyz", strings.NewReader("payload"))
+	requestService, _ := hmac.NewRequestService(public, private)
+	requestService.SignRequest(request)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	var report serveReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	return recorder.Code, report
+}
+
+func TestThatServeReportsValidation(t *testing.T) {
+	public, private := testKeys(t)
+	otherPublic, otherPrivate := testKeys(t)
+
+	var credentials credentialListFlag
+	credentials.Set(otherPublic + ":" + otherPrivate)
+	store := credentialMap{otherPublic: credentials[0]}
+	authenticator, _ := hmac.NewAuthenticator(public, private, 300, hmac.WithCredentialStore(store))
+	handler := newServeHandler(authenticator, time.Now)
+
+	code, report := serveTestRequest(t, handler, otherPublic, otherPrivate)
+	if code != http.StatusOK || !report.Valid || report.Credential != otherPublic {
+		t.Fatalf("expected valid report for %s, got %d %+v", otherPublic, code, report)
+	}
+	if report.Received.Body != "payload" || !strings.HasPrefix(report.CanonicalRequest, "POST localhost:8080/items?abc=xyz\n") {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	_, wrongPrivate := testKeys(t)
+	code, report = serveTestRequest(t, handler, public, wrongPrivate)
+	if code != http.StatusForbidden || report.Valid || report.Reason != hmac.ReasonInvalidSignature {
+		t.Fatalf("expected invalid signature report, got %d %+v", code, report)
+	}
+	if report.StringToSign == "" {
+		t.Fatal("expected string to sign in report")
+	}
+	for _, step := range report.Steps {
+		if step.Name == "signature" && step.Detail != "does not match" {
+			t.Fatalf("expected no expected signature in report, got %q", step.Detail)
+		}
+	}
+
+	unknownPublic, _ := testKeys(t)
+	code, report = serveTestRequest(t, handler, unknownPublic, wrongPrivate)
+	if code != http.StatusForbidden || report.Reason != hmac.ReasonUnknownCredential || report.CanonicalRequest == "" {
+		t.Fatalf("expected unknown credential report with canonical request, got %d %+v", code, report)
+	}
+}
+
+func TestThatServeSignsResponses(t *testing.T) {
+	public, private := testKeys(t)
+
+	authenticator, _ := hmac.NewAuthenticator(public, private, 300)
+	requestService, _ := hmac.NewRequestService(public, private)
+	handler := requestService.SigningMiddleware(newServeHandler(authenticator, time.Now))
+
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/", nil)
+	requestService.SignRequest(request)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	response.Request = request
+	if _, err := authenticator.AuthenticateResponse(response); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThatServeGeneratesSeparateResponseKeys(t *testing.T) {
+	var stdout strings.Builder
+	requestService, err := newResponseSigner(keyFlags{}, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := strings.Fields(stdout.String())
+	if len(fields) != 10 {
+		t.Fatalf("expected generated keys to be printed, got %q", stdout.String())
+	}
+	authenticator, _ := hmac.NewAuthenticator(fields[5], fields[9], 300)
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/", nil)
+	requestService.SignRequest(request)
+	if _, err := authenticator.Authenticate(request); err != nil {
+		t.Fatalf("expected printed keys to verify, got %v", err)
+	}
+}
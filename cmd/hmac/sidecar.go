@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+func runSidecar(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("sidecar", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", "localhost:8080", "listen `address`")
+	upstream := fs.String("upstream", "", "upstream `URL` to forward authenticated requests to")
+	var credentials credentialFlags
+	credentials.register(fs)
+	var extra credentialListFlag
+	fs.Var(&extra, "credential", "additional accepted credential `public:private` (repeatable)")
+	tolerance := fs.Int64("tolerance", 300, "allowed clock skew in seconds")
+	credentialHeader := fs.String("credential-header", hmac.DefaultCredentialHeader, "header in which the authenticated credential is forwarded")
+	var upstreamKeys keyFlags
+	fs.StringVar(&upstreamKeys.public, "upstream-public", "", "public key used to re-sign forwarded requests")
+	fs.StringVar(&upstreamKeys.private, "upstream-private", "", "private key used to re-sign forwarded requests (hex, base64 or base64url)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	target, err := url.Parse(*upstream)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		fmt.Fprintln(stderr, "hmac sidecar: -upstream must be an absolute URL")
+		return 2
+	}
+
+	authenticator, _, err := newAuthenticator(&credentials, extra, *tolerance)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac sidecar: %v\n", err)
+		return 2
+	}
+
+	options := []hmac.ReverseProxyOption{hmac.WithCredentialHeader(*credentialHeader)}
+	if upstreamKeys.public != "" || upstreamKeys.private != "" {
+		signer, err := hmac.NewRequestService(upstreamKeys.public, upstreamKeys.private)
+		if err != nil {
+			fmt.Fprintf(stderr, "hmac sidecar: upstream keys: %v\n", err)
+			return 2
+		}
+		options = append(options, hmac.WithUpstreamSigner(signer))
+	}
+
+	fmt.Fprintf(stdout, "listening on %s, forwarding to %s\n", *addr, target)
+	if err := http.ListenAndServe(*addr, hmac.NewReverseProxy(target, authenticator, options...)); err != nil {
+		fmt.Fprintf(stderr, "hmac sidecar: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
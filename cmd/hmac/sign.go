@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+func runSign(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("sign", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var credentials credentialFlags
+	credentials.register(fs)
+	var req requestFlags
+	req.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if _, err := credentials.resolve(); err != nil {
+		fmt.Fprintf(stderr, "hmac sign: %v\n", err)
+		return 2
+	}
+	keys := credentials.keys
+
+	request, err := req.request(stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac sign: %v\n", err)
+		return 2
+	}
+
+	requestService, err := hmac.NewRequestService(keys.public, keys.private)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac sign: %v\n", err)
+		return 2
+	}
+
+	signedRequest, err := requestService.SignRequest(request)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac sign: %v\n", err)
+		return 1
+	}
+
+	printHeaders(stdout, signedRequest.Header)
+	return 0
+}
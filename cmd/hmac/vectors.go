@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pascalallen/hmac/v2/conformance"
+)
+
+func runVectors(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	usage := func() {
+		fmt.Fprintln(stderr, "Usage: hmac vectors <generate|run|serve> [flags]")
+		fmt.Fprintln(stderr, "\n  generate [-o file]                  write the conformance test vectors")
+		fmt.Fprintln(stderr, "  run [-vectors file] command [args]  check an implementation over stdin/stdout")
+		fmt.Fprintln(stderr, "  serve                               answer the protocol with this implementation")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	switch args[0] {
+	case "generate":
+		return runVectorsGenerate(args[1:], stdout, stderr)
+	case "run":
+		return runVectorsRun(args[1:], stdout, stderr)
+	case "serve":
+		if err := conformance.Serve(stdin, stdout); err != nil {
+			fmt.Fprintf(stderr, "hmac vectors serve: %v\n", err)
+			return 1
+		}
+		return 0
+	default:
+		usage()
+		return 2
+	}
+}
+
+func runVectorsGenerate(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("vectors generate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("o", "", "write the vectors to `file` instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	corpus, err := conformance.Generate()
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac vectors generate: %v\n", err)
+		return 1
+	}
+
+	w := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(stderr, "hmac vectors generate: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := conformance.Write(w, corpus); err != nil {
+		fmt.Fprintf(stderr, "hmac vectors generate: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func runVectorsRun(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("vectors run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	vectors := fs.String("vectors", "", "read the vectors from `file` instead of generating them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "hmac vectors run: command required")
+		return 2
+	}
+
+	corpus, err := loadCorpus(*vectors)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac vectors run: %v\n", err)
+		return 1
+	}
+
+	report, err := conformance.RunCommand(context.Background(), corpus, fs.Arg(0), fs.Args()[1:]...)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac vectors run: %v\n", err)
+		return 1
+	}
+
+	for _, failure := range report.Failures {
+		fmt.Fprintf(stdout, "FAIL %s\n", failure)
+	}
+	fmt.Fprintf(stdout, "corpus v%d: %d passed, %d failed, %d skipped\n", corpus.Version, report.Passed, len(report.Failures), report.Skipped)
+	if !report.OK() {
+		return 1
+	}
+
+	return 0
+}
+
+func loadCorpus(path string) (*conformance.Corpus, error) {
+	if path == "" {
+		return conformance.Generate()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return conformance.Read(f)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pascalallen/hmac/v2/conformance"
+)
+
+func TestThatVectorsGenerateWritesCorpus(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "vectors.json")
+
+	code, _, stderr := runCommand(t, "", "vectors", "generate", "-o", output)
+	if code != 0 {
+		t.Fatalf("vectors generate failed: %s", stderr)
+	}
+
+	corpus, err := loadCorpus(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if corpus.Version != conformance.Version || len(corpus.Vectors) == 0 {
+		t.Fatalf("unexpected corpus version %d with %d vectors", corpus.Version, len(corpus.Vectors))
+	}
+}
+
+func TestThatVectorsRunRequiresCommand(t *testing.T) {
+	if code, _, _ := runCommand(t, "", "vectors", "run"); code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+}
+
+func TestThatLoadCorpusRejectsMalformedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.json")
+	os.WriteFile(path, []byte("not json"), 0600)
+
+	if _, err := loadCorpus(path); err == nil {
+		t.Fatal("expected error for malformed corpus")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+func runVerify(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var credentials credentialFlags
+	credentials.register(fs)
+	var req requestFlags
+	req.register(fs)
+	tolerance := fs.Int64("tolerance", 300, "allowed clock skew in seconds")
+	debug := fs.Bool("debug", false, "print the canonical request and string to sign on mismatch")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if _, err := credentials.resolve(); err != nil {
+		fmt.Fprintf(stderr, "hmac verify: %v\n", err)
+		return 2
+	}
+	keys := credentials.keys
+
+	request, err := req.request(stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac verify: %v\n", err)
+		return 2
+	}
+
+	var options []hmac.AuthenticatorOption
+	if *debug {
+		options = append(options, hmac.WithDebug())
+	}
+	authenticator, err := hmac.NewAuthenticator(keys.public, keys.private, *tolerance, options...)
+	if err != nil {
+		fmt.Fprintf(stderr, "hmac verify: %v\n", err)
+		return 2
+	}
+
+	result, err := authenticator.Authenticate(request)
+	if err != nil {
+		var validationErr *hmac.ValidationError
+		if !errors.As(err, &validationErr) {
+			fmt.Fprintf(stderr, "hmac verify: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "invalid: %s (%s)\n", validationErr.Message, validationErr.Reason)
+		if validationErr.CanonicalRequest != "" {
+			fmt.Fprintf(stdout, "\ncanonical request:\n%s\nstring to sign:\n%s\n", validationErr.CanonicalRequest, validationErr.StringToSign)
+		}
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "valid: signed by %s at %s\n", result.Credential, result.Timestamp.UTC().Format("2006-01-02T15:04:05Z"))
+	return 0
+}
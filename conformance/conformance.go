@@ -0,0 +1,365 @@
+// Package conformance provides test vectors for implementations of the
+// signing scheme of github.com/pascalallen/hmac/v2 in other languages, and a
+// runner that checks such an implementation over a line-based JSON protocol.
+//
+// The vectors are generated from the library with fixed keys, timestamps and
+// nonces, so Generate always returns the same corpus for a given Version.
+package conformance
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+// Version is the version of the corpus returned by Generate. It changes
+// whenever existing vectors change.
+const Version = 2
+
+// Corpus is a versioned set of test vectors.
+type Corpus struct {
+	Version int      `json:"version"`
+	Vectors []Vector `json:"vectors"`
+}
+
+// Vector is a single test case. Every vector has signing inputs and the
+// expected intermediate values. Vectors with a Validation also describe a
+// request as received by a server and the expected validation outcome.
+type Vector struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Input       Input       `json:"input"`
+	Expected    Expected    `json:"expected"`
+	Validation  *Validation `json:"validation,omitempty"`
+}
+
+// Input holds the values a client signs. Path is the decoded URL path and
+// Query the raw query string, as in net/url.URL.
+//
+// Scope, if set, is the X-Scope header, and the signature is made with the
+// key derived for that scope. Headers are additional headers that are signed
+// and listed in X-Signed-Headers. When Status is set, the Input is a response
+// with that status code answering a request with the X-Nonce RequestNonce,
+// and Method, Authority, Path and Query are empty.
+type Input struct {
+	Method       string            `json:"method"`
+	Authority    string            `json:"authority"`
+	Path         string            `json:"path"`
+	Query        string            `json:"query"`
+	BodyBase64   string            `json:"body_base64"`
+	Timestamp    int64             `json:"timestamp"`
+	Nonce        string            `json:"nonce"`
+	Public       string            `json:"public"`
+	Private      string            `json:"private"`
+	Scope        string            `json:"scope,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Status       int               `json:"status,omitempty"`
+	RequestNonce string            `json:"request_nonce,omitempty"`
+}
+
+// Expected holds the values an implementation must compute from an Input.
+// ContentHash is empty when the body is empty.
+type Expected struct {
+	ContentHash      string `json:"content_hash"`
+	CanonicalRequest string `json:"canonical_request"`
+	StringToSign     string `json:"string_to_sign"`
+	Signature        string `json:"signature"`
+}
+
+// Validation describes a signed request, or response, as received, after
+// Overrides are applied to the headers produced by signing the Input, and the
+// expected outcome of validating it with the Input keys at ServerTime.
+// An override with an empty value removes the header.
+type Validation struct {
+	Overrides  map[string]string `json:"overrides,omitempty"`
+	Headers    map[string]string `json:"headers"`
+	ServerTime int64             `json:"server_time"`
+	Tolerance  int64             `json:"tolerance"`
+	Valid      bool              `json:"valid"`
+	Reason     hmac.Reason       `json:"reason,omitempty"`
+}
+
+const (
+	vectorPublic       = "a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	vectorPrivate      = "00112233445566778899aabbccddeeff"
+	vectorTime         = 1700000000
+	vectorRequestNonce = "0123456789abcdef"
+)
+
+type testCase struct {
+	name        string
+	description string
+	method      string
+	authority   string
+	path        string
+	query       string
+	body        []byte
+	overrides   map[string]string
+	skew        int64
+	// scope is the environment and service of a scoped request.
+	scope   [2]string
+	headers map[string]string
+	status  int
+}
+
+var cases = []testCase{
+	{name: "get-root", description: "GET without body", method: "GET", authority: "localhost:8080", path: "/"},
+	{name: "empty-path", description: "an empty path is canonicalized to /", method: "GET", authority: "localhost:8080", path: ""},
+	{name: "post-json", description: "POST with a JSON body and query", method: "POST", authority: "localhost:8080", path: "/items", query: "abc=xyz", body: []byte(`{"foo": "bar"}`)},
+	{name: "post-empty-body", description: "POST with an empty body has no content hash", method: "POST", authority: "api.example.com", path: "/items"},
+	{name: "unicode-path-and-body", description: "the decoded path and UTF-8 body are signed", method: "PUT", authority: "api.example.com", path: "/café/日本", body: []byte(`{"name": "Zoë 👋"}`)},
+	{name: "binary-body", description: "bodies are hashed as raw bytes", method: "POST", authority: "api.example.com", path: "/upload", body: []byte{0x00, 0xff, 0x10, 0x80, 0x7f}},
+	{name: "query-unsorted-repeated", description: "queries are signed byte for byte, not sorted", method: "GET", authority: "api.example.com", path: "/search", query: "z=1&a=2&a=1&empty=&flag"},
+	{name: "query-encoded", description: "percent-encoding in queries is preserved", method: "GET", authority: "api.example.com", path: "/search", query: "q=caf%C3%A9+au+lait&x=%2F%3F"},
+	{name: "query-trailing-ampersand", description: "trailing separators are part of the query", method: "DELETE", authority: "api.example.com:443", path: "/items/1", query: "force=true&"},
+	{name: "invalid-signature", description: "a modified signature is rejected", method: "GET", authority: "localhost:8080", path: "/", overrides: map[string]string{"Signature": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}},
+	{name: "modified-nonce", description: "the nonce is covered by the signature", method: "GET", authority: "localhost:8080", path: "/", overrides: map[string]string{"X-Nonce": "ffffffffffffffff"}},
+	{name: "missing-nonce", description: "X-Nonce is required", method: "GET", authority: "localhost:8080", path: "/", overrides: map[string]string{"X-Nonce": ""}},
+	{name: "invalid-content-hash", description: "the content hash must match the body", method: "POST", authority: "localhost:8080", path: "/items", body: []byte("payload"), overrides: map[string]string{"X-Content-SHA256": "bm90IHRoZSBoYXNo"}},
+	{name: "missing-content-hash", description: "X-Content-SHA256 is required with a body", method: "POST", authority: "localhost:8080", path: "/items", body: []byte("payload"), overrides: map[string]string{"X-Content-SHA256": ""}},
+	{name: "future-within-tolerance", description: "timestamps up to the tolerance in the future are accepted", method: "GET", authority: "localhost:8080", path: "/", skew: -299},
+	{name: "past-outside-tolerance", description: "timestamps older than the tolerance are rejected", method: "GET", authority: "localhost:8080", path: "/", skew: 301},
+	{name: "scoped-request", description: "X-Scope is signed and selects the key derived for its date, environment and service", method: "GET", authority: "api.example.com", path: "/orders", scope: [2]string{"production", "orders"}},
+	{name: "scoped-request-with-body", description: "scoped requests hash their body like unscoped ones", method: "POST", authority: "api.example.com", path: "/orders", body: []byte(`{"id": 1}`), scope: [2]string{"staging", "orders"}},
+	{name: "scope-date-mismatch", description: "the scope date must be the UTC day of the timestamp", method: "GET", authority: "api.example.com", path: "/orders", scope: [2]string{"production", "orders"}, overrides: map[string]string{"X-Scope": "20231113/production/orders"}},
+	{name: "signed-headers", description: "headers listed in X-Signed-Headers are signed under their canonical names", method: "POST", authority: "api.example.com", path: "/items", body: []byte(`{"foo": "bar"}`), headers: map[string]string{"content-type": "application/json", "X-Request-Id": "req-42"}},
+	{name: "modified-signed-header", description: "signed headers are covered by the signature", method: "POST", authority: "api.example.com", path: "/items", body: []byte(`{"foo": "bar"}`), headers: map[string]string{"X-Request-Id": "req-42"}, overrides: map[string]string{"X-Request-Id": "req-43"}},
+	{name: "response", description: "responses are signed with the status code and the nonce of the request", body: []byte(`{"id": 1}`), status: 200},
+	{name: "response-without-body", description: "responses without a body have no content hash", status: 204},
+	{name: "response-invalid-signature", description: "a modified response signature is rejected", status: 404, overrides: map[string]string{"Signature": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}},
+}
+
+// Generate returns the current corpus.
+func Generate() (*Corpus, error) {
+	corpus := &Corpus{Version: Version}
+
+	for i, c := range cases {
+		input := Input{
+			Method:     c.method,
+			Authority:  c.authority,
+			Path:       c.path,
+			Query:      c.query,
+			BodyBase64: base64.StdEncoding.EncodeToString(c.body),
+			Timestamp:  vectorTime + int64(i)*60,
+			Nonce:      hex.EncodeToString([]byte{0x5e, 0xed, byte(i), 0x00, 0x01, 0x02, 0x03, 0x04}),
+			Public:     vectorPublic,
+			Private:    vectorPrivate,
+			Headers:    c.headers,
+			Status:     c.status,
+		}
+		if c.scope[0] != "" {
+			input.Scope = hmac.NewScope(time.Unix(input.Timestamp, 0), c.scope[0], c.scope[1]).String()
+		}
+		if c.status != 0 {
+			input.RequestNonce = vectorRequestNonce
+		}
+
+		expected, err := Compute(input)
+		if err != nil {
+			return nil, err
+		}
+
+		headers := signedHeaders(input, expected)
+		for name, value := range c.overrides {
+			if value == "" {
+				delete(headers, name)
+				continue
+			}
+			headers[name] = value
+		}
+
+		validation := &Validation{
+			Overrides:  c.overrides,
+			Headers:    headers,
+			ServerTime: input.Timestamp + c.skew,
+			Tolerance:  300,
+		}
+		if err := validate(input, validation); err != nil {
+			return nil, err
+		}
+
+		corpus.Vectors = append(corpus.Vectors, Vector{
+			Name:        c.name,
+			Description: c.description,
+			Input:       input,
+			Expected:    expected,
+			Validation:  validation,
+		})
+	}
+
+	return corpus, nil
+}
+
+// Read decodes a corpus written by Write.
+func Read(r io.Reader) (*Corpus, error) {
+	var corpus Corpus
+	if err := json.NewDecoder(r).Decode(&corpus); err != nil {
+		return nil, fmt.Errorf("malformed corpus: %w", err)
+	}
+
+	return &corpus, nil
+}
+
+// Write encodes corpus as indented JSON.
+func Write(w io.Writer, corpus *Corpus) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+
+	return encoder.Encode(corpus)
+}
+
+// Compute returns the values this library computes for input.
+func Compute(input Input) (Expected, error) {
+	body, err := base64.StdEncoding.DecodeString(input.BodyBase64)
+	if err != nil {
+		return Expected{}, err
+	}
+	private, err := hex.DecodeString(input.Private)
+	if err != nil {
+		return Expected{}, errors.New("invalid private key")
+	}
+
+	var expected Expected
+	headers := map[string]string{
+		"X-Timestamp": strconv.FormatInt(input.Timestamp, 10),
+		"X-Nonce":     input.Nonce,
+	}
+	if len(body) > 0 {
+		contentHash := sha256.Sum256(body)
+		expected.ContentHash = base64.StdEncoding.EncodeToString(contentHash[:])
+		headers["X-Content-SHA256"] = expected.ContentHash
+	}
+
+	if input.Scope != "" {
+		headers["X-Scope"] = input.Scope
+	}
+	if names := signedHeaderNames(input); len(names) > 0 {
+		headers["X-Signed-Headers"] = strings.Join(names, ",")
+		for name, value := range input.Headers {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+
+	if input.Status != 0 {
+		expected.CanonicalRequest = hmac.CreateCanonicalResponseString(input.Status, input.RequestNonce, headers)
+	} else {
+		expected.CanonicalRequest = hmac.CreateCanonicalRequestString(input.Method, input.Authority, input.Path, input.Query, headers)
+	}
+	expected.StringToSign = hmac.CreateStringToSign(expected.CanonicalRequest, input.Timestamp)
+	if input.Scope != "" {
+		scope, err := hmac.ParseScope(input.Scope)
+		if err != nil {
+			return Expected{}, err
+		}
+		expected.Signature = hmac.CreateScopedSignature(expected.CanonicalRequest, input.Timestamp, hmac.DeriveScopedKey(private, scope))
+	} else {
+		expected.Signature = hmac.CreateSignature(expected.CanonicalRequest, input.Timestamp, string(private))
+	}
+
+	return expected, nil
+}
+
+// signedHeaderNames returns the canonical names of the Headers of input,
+// sorted, as listed in X-Signed-Headers.
+func signedHeaderNames(input Input) []string {
+	names := make([]string, 0, len(input.Headers))
+	for name := range input.Headers {
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// signedHeaders returns the headers of a request signed with input.
+func signedHeaders(input Input, expected Expected) map[string]string {
+	headers := map[string]string{
+		"Authorization": "HMAC-SHA256",
+		"Credential":    input.Public,
+		"Signature":     expected.Signature,
+		"X-Timestamp":   strconv.FormatInt(input.Timestamp, 10),
+		"X-Nonce":       input.Nonce,
+	}
+	if expected.ContentHash != "" {
+		headers["X-Content-SHA256"] = expected.ContentHash
+	}
+	if input.Scope != "" {
+		headers["X-Scope"] = input.Scope
+	}
+	if names := signedHeaderNames(input); len(names) > 0 {
+		headers["X-Signed-Headers"] = strings.Join(names, ",")
+		for name, value := range input.Headers {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+
+	return headers
+}
+
+// validate fills in the outcome of validating the request or response
+// described by input and v with an Authenticator.
+func validate(input Input, v *Validation) error {
+	request, err := validationRequest(input, v)
+	if err != nil {
+		return err
+	}
+
+	authenticator, err := hmac.NewAuthenticator(input.Public, input.Private, v.Tolerance, hmac.WithClock(func() time.Time {
+		return time.Unix(v.ServerTime, 0)
+	}))
+	if err != nil {
+		return err
+	}
+
+	if input.Status != 0 {
+		answered := &http.Request{Header: http.Header{"X-Nonce": {input.RequestNonce}}}
+		_, err = authenticator.AuthenticateResponse(&http.Response{
+			StatusCode: input.Status,
+			Header:     request.Header,
+			Body:       request.Body,
+			Request:    answered,
+		})
+	} else {
+		_, err = authenticator.Authenticate(request)
+	}
+	v.Valid = err == nil
+	var validationErr *hmac.ValidationError
+	if errors.As(err, &validationErr) {
+		v.Reason = validationErr.Reason
+	}
+
+	return nil
+}
+
+func validationRequest(input Input, v *Validation) (*http.Request, error) {
+	body, err := base64.StdEncoding.DecodeString(input.BodyBase64)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &http.Request{
+		Method: input.Method,
+		URL:    &url.URL{Path: input.Path, RawQuery: input.Query},
+		Host:   input.Authority,
+		Header: make(http.Header),
+		Body:   io.NopCloser(strings.NewReader(string(body))),
+	}
+	for name, value := range v.Headers {
+		request.Header.Set(name, value)
+	}
+
+	return request, nil
+}
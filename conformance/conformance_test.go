@@ -0,0 +1,159 @@
+package conformance
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+// The golden corpus is regenerated with
+//
+//	go run ./cmd/hmac vectors generate -o conformance/testdata/vectors-v2.json
+//
+// Any change to it must come with a new Version.
+func TestThatGenerateMatchesGoldenCorpus(t *testing.T) {
+	golden, err := os.ReadFile("testdata/vectors-v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corpus, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var generated bytes.Buffer
+	if err := Write(&generated, corpus); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(generated.Bytes(), golden) {
+		t.Fatal("generated corpus differs from testdata/vectors-v2.json")
+	}
+}
+
+func TestThatCorpusHasExpectedOutcomes(t *testing.T) {
+	corpus, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outcomes := map[string]hmac.Reason{
+		"get-root":                   "",
+		"unicode-path-and-body":      "",
+		"invalid-signature":          hmac.ReasonInvalidSignature,
+		"modified-nonce":             hmac.ReasonInvalidSignature,
+		"missing-nonce":              hmac.ReasonMissingHeader,
+		"invalid-content-hash":       hmac.ReasonInvalidContentHash,
+		"missing-content-hash":       hmac.ReasonMissingContentHash,
+		"future-within-tolerance":    "",
+		"past-outside-tolerance":     hmac.ReasonTimestampOutOfBounds,
+		"scoped-request":             "",
+		"scope-date-mismatch":        hmac.ReasonInvalidScope,
+		"signed-headers":             "",
+		"modified-signed-header":     hmac.ReasonInvalidSignature,
+		"response":                   "",
+		"response-without-body":      "",
+		"response-invalid-signature": hmac.ReasonInvalidSignature,
+	}
+	for _, v := range corpus.Vectors {
+		reason, ok := outcomes[v.Name]
+		if !ok {
+			continue
+		}
+		if v.Validation.Valid != (reason == "") || v.Validation.Reason != reason {
+			t.Errorf("%s: expected reason %q, got valid %v, reason %q", v.Name, reason, v.Validation.Valid, v.Validation.Reason)
+		}
+	}
+}
+
+func TestThatReadRoundTripsCorpus(t *testing.T) {
+	f, err := os.Open("testdata/vectors-v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	corpus, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if corpus.Version != Version || len(corpus.Vectors) != len(cases) {
+		t.Fatalf("unexpected corpus version %d with %d vectors", corpus.Version, len(corpus.Vectors))
+	}
+}
+
+func TestThatReferenceImplementationPassesPreviousCorpus(t *testing.T) {
+	f, err := os.Open("testdata/vectors-v1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	corpus, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := runServe(t, corpus, Serve); !report.OK() {
+		t.Fatalf("unexpected failures: %v", report.Failures)
+	}
+}
+
+func runServe(t *testing.T, corpus *Corpus, serve func(r io.Reader, w io.Writer) error) *Report {
+	t.Helper()
+
+	requestReader, requestWriter := io.Pipe()
+	responseReader, responseWriter := io.Pipe()
+	go func() {
+		responseWriter.CloseWithError(serve(requestReader, responseWriter))
+	}()
+
+	report, err := Run(corpus, requestWriter, responseReader)
+	requestWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return report
+}
+
+func TestThatReferenceImplementationPasses(t *testing.T) {
+	corpus, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := runServe(t, corpus, Serve)
+	if !report.OK() {
+		t.Fatalf("unexpected failures: %v", report.Failures)
+	}
+	if report.Passed != 2*len(corpus.Vectors) || report.Skipped != 0 {
+		t.Fatalf("expected %d checks to pass, got %d passed, %d skipped", 2*len(corpus.Vectors), report.Passed, report.Skipped)
+	}
+}
+
+func TestThatRunDetectsBrokenImplementation(t *testing.T) {
+	corpus, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	corpus.Vectors[0].Expected.Signature = "broken"
+
+	report := runServe(t, corpus, Serve)
+	if report.OK() || len(report.Failures) != 1 || report.Failures[0].Field != "signature" {
+		t.Fatalf("expected a single signature failure, got %v", report.Failures)
+	}
+}
+
+func TestThatUnsupportedOperationsAreSkipped(t *testing.T) {
+	report := &Report{}
+	report.check("get-root", "validate", &Response{Error: "unsupported"}, func(fail func(string, string, string)) {
+		fail("valid", "true", "false")
+	})
+
+	if !report.OK() || report.Skipped != 1 || report.Passed != 0 {
+		t.Fatalf("expected a skipped check, got %+v", report)
+	}
+}
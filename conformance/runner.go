@@ -0,0 +1,248 @@
+package conformance
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+// Request is a line sent by the runner to an implementation under test.
+// Op is "sign" or "validate". Validation is only set for "validate" and
+// does not include the expected outcome. Inputs with a Status describe
+// responses, which are signed and validated as such.
+type Request struct {
+	Op         string             `json:"op"`
+	Vector     string             `json:"vector"`
+	Input      Input              `json:"input"`
+	Validation *ValidationRequest `json:"validation,omitempty"`
+}
+
+// ValidationRequest describes the request to validate.
+type ValidationRequest struct {
+	Headers    map[string]string `json:"headers"`
+	ServerTime int64             `json:"server_time"`
+	Tolerance  int64             `json:"tolerance"`
+}
+
+// Response is a line written by an implementation in answer to a Request.
+// A "sign" request is answered with the Expected fields and a "validate"
+// request with Valid and, optionally, Reason. An implementation that does
+// not support an operation answers with Error set to "unsupported".
+type Response struct {
+	Expected
+	Valid  bool        `json:"valid"`
+	Reason hmac.Reason `json:"reason,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Failure is a value computed by an implementation that does not match the
+// corpus.
+type Failure struct {
+	Vector   string
+	Op       string
+	Field    string
+	Expected string
+	Got      string
+}
+
+func (f Failure) String() string {
+	return fmt.Sprintf("%s %s: %s: expected %q, got %q", f.Vector, f.Op, f.Field, f.Expected, f.Got)
+}
+
+// Report is the outcome of running a corpus against an implementation.
+type Report struct {
+	Passed   int
+	Skipped  int
+	Failures []Failure
+}
+
+// OK reports whether every check passed.
+func (r *Report) OK() bool {
+	return len(r.Failures) == 0
+}
+
+// Run checks an implementation that reads Requests from w and writes
+// Responses to r, one JSON document per line.
+func Run(corpus *Corpus, w io.Writer, r io.Reader) (*Report, error) {
+	report := &Report{}
+	encoder := json.NewEncoder(w)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	exchange := func(request Request) (*Response, error) {
+		if err := encoder.Encode(request); err != nil {
+			return nil, fmt.Errorf("%s: unable to write request: %w", request.Vector, err)
+		}
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, fmt.Errorf("%s: unable to read response: %w", request.Vector, err)
+			}
+			return nil, fmt.Errorf("%s: unable to read response: %w", request.Vector, io.ErrUnexpectedEOF)
+		}
+
+		var response Response
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			return nil, fmt.Errorf("%s: malformed response: %w", request.Vector, err)
+		}
+
+		return &response, nil
+	}
+
+	for _, v := range corpus.Vectors {
+		response, err := exchange(Request{Op: "sign", Vector: v.Name, Input: v.Input})
+		if err != nil {
+			return report, err
+		}
+		report.check(v.Name, "sign", response, func(fail func(field string, expected string, got string)) {
+			fields := [][3]string{
+				{"content_hash", v.Expected.ContentHash, response.ContentHash},
+				{"canonical_request", v.Expected.CanonicalRequest, response.CanonicalRequest},
+				{"string_to_sign", v.Expected.StringToSign, response.StringToSign},
+				{"signature", v.Expected.Signature, response.Signature},
+			}
+			for _, f := range fields {
+				if f[1] != f[2] {
+					fail(f[0], f[1], f[2])
+				}
+			}
+		})
+
+		if v.Validation == nil {
+			continue
+		}
+		response, err = exchange(Request{
+			Op:     "validate",
+			Vector: v.Name,
+			Input:  v.Input,
+			Validation: &ValidationRequest{
+				Headers:    v.Validation.Headers,
+				ServerTime: v.Validation.ServerTime,
+				Tolerance:  v.Validation.Tolerance,
+			},
+		})
+		if err != nil {
+			return report, err
+		}
+		report.check(v.Name, "validate", response, func(fail func(field string, expected string, got string)) {
+			if response.Valid != v.Validation.Valid {
+				fail("valid", fmt.Sprint(v.Validation.Valid), fmt.Sprint(response.Valid))
+			}
+			if response.Reason != "" && response.Reason != v.Validation.Reason {
+				fail("reason", string(v.Validation.Reason), string(response.Reason))
+			}
+		})
+	}
+
+	return report, nil
+}
+
+func (r *Report) check(vector string, op string, response *Response, compare func(fail func(field string, expected string, got string))) {
+	if response.Error == "unsupported" {
+		r.Skipped++
+		return
+	}
+	if response.Error != "" {
+		r.Failures = append(r.Failures, Failure{Vector: vector, Op: op, Field: "error", Got: response.Error})
+		return
+	}
+
+	failed := false
+	compare(func(field string, expected string, got string) {
+		failed = true
+		r.Failures = append(r.Failures, Failure{Vector: vector, Op: op, Field: field, Expected: expected, Got: got})
+	})
+	if !failed {
+		r.Passed++
+	}
+}
+
+// RunCommand starts an implementation with the given command and runs the
+// corpus against its standard input and output.
+func RunCommand(ctx context.Context, corpus *Corpus, name string, args ...string) (*Report, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	report, runErr := Run(corpus, stdin, stdout)
+	stdin.Close()
+	waitErr := cmd.Wait()
+	if runErr != nil {
+		return report, runErr
+	}
+	if waitErr != nil {
+		return report, fmt.Errorf("implementation exited with error: %w", waitErr)
+	}
+
+	return report, nil
+}
+
+// Serve answers Requests read from r with the Responses of this library,
+// until r is exhausted. It is the reference implementation of the protocol.
+func Serve(r io.Reader, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var request Request
+		var response Response
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			response.Error = err.Error()
+		} else {
+			response = serve(request)
+		}
+
+		if err := encoder.Encode(response); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func serve(request Request) Response {
+	var response Response
+
+	switch request.Op {
+	case "sign":
+		expected, err := Compute(request.Input)
+		if err != nil {
+			response.Error = err.Error()
+			break
+		}
+		response.Expected = expected
+	case "validate":
+		if request.Validation == nil {
+			response.Error = "validation required"
+			break
+		}
+		v := &Validation{
+			Headers:    request.Validation.Headers,
+			ServerTime: request.Validation.ServerTime,
+			Tolerance:  request.Validation.Tolerance,
+		}
+		if err := validate(request.Input, v); err != nil {
+			response.Error = err.Error()
+			break
+		}
+		response.Valid, response.Reason = v.Valid, v.Reason
+	default:
+		response.Error = "unsupported"
+	}
+
+	return response
+}
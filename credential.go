@@ -0,0 +1,100 @@
+package hmac
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Credential is a key pair accepted by an Authenticator. ID is the public
+// key clients send in the Credential header. Metadata is opaque to this
+// package and is returned in the Result of requests signed by the credential.
+// Debug enables debug mode for this credential only, see WithDebug.
+//
+// When Scope is set, Private is the key returned by DeriveScopedKey for that
+// scope rather than a private key, and the credential only validates requests
+// of that scope. Policy, if set, restricts the requests the credential may
+// make, and RateLimit how often it may make them, see WithRateLimiter.
+//
+// A credential is rejected once it is Revoked or past ExpiresAt, if set. See
+// also WithRevocationList.
+//
+// Verifier, if set, checks signatures instead of Private, so that the private
+// key can be held by a KMS or HSM. See NewKey.
+type Credential struct {
+	ID        string
+	Private   []byte
+	Verifier  Verifier
+	Metadata  map[string]string
+	Debug     bool
+	Scope     string
+	Policy    *Policy
+	RateLimit *RateLimit
+	ExpiresAt time.Time
+	Revoked   bool
+}
+
+// NewCredential creates a credential from a public key and a private key
+// parsed with ParseSecretKey, such as one produced by GenerateSecureRandom.
+func NewCredential(public string, private string) (*Credential, error) {
+	if len(public) == 0 {
+		return nil, fmt.Errorf("public key required")
+	}
+
+	key, err := ParseSecretKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{ID: public, Private: key.Bytes()}, nil
+}
+
+// LogValue implements slog.LogValuer. The private key is never included.
+func (c Credential) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("id", c.ID))
+}
+
+// String returns the credential ID, so that formatting a credential with
+// %v or %s does not print its private key.
+func (c Credential) String() string {
+	return c.ID
+}
+
+// Format implements fmt.Formatter, so that no verb prints the private key.
+func (c Credential) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, c.String())
+}
+
+// MarshalJSON encodes the credential with a placeholder instead of its
+// private key.
+func (c Credential) MarshalJSON() ([]byte, error) {
+	type plain Credential
+	redactedCredential := struct {
+		plain
+		Private string `json:",omitempty"`
+	}{plain: plain(c)}
+	redactedCredential.plain.Verifier = nil
+	if len(c.Private) > 0 {
+		redactedCredential.Private = redacted
+	}
+
+	return json.Marshal(redactedCredential)
+}
+
+// Zero overwrites the private key of the credential with zeros, and that of
+// its Verifier if it is a SecretKey. Call it once the credential is removed
+// from its store and no request is being validated with it.
+func (c *Credential) Zero() {
+	clear(c.Private)
+	if key, ok := c.Verifier.(SecretKey); ok {
+		key.Zero()
+	}
+}
+
+// CredentialStore looks up credentials by ID. It lets a single Authenticator
+// accept requests from many clients.
+type CredentialStore interface {
+	Credential(id string) (*Credential, bool)
+}
@@ -0,0 +1,273 @@
+package hmac
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+)
+
+// credentialFileVersion is the version of the encrypted credential file
+// format.
+const credentialFileVersion = 1
+
+// pbkdf2Iterations is the number of PBKDF2-SHA256 iterations used to derive
+// a key from a passphrase. Files with fewer than minPBKDF2Iterations, which
+// would weaken the passphrase, or more than maxPBKDF2Iterations, which would
+// stall loading, are rejected.
+const (
+	pbkdf2Iterations    = 600000
+	minPBKDF2Iterations = 100000
+	maxPBKDF2Iterations = 10000000
+)
+
+// ErrCredentialFileKey is returned when an encrypted credential file cannot
+// be opened with the given key, because the key is wrong or the file has
+// been modified.
+var ErrCredentialFileKey = errors.New("wrong key or modified credential file")
+
+// FileKey is the key of an encrypted credential file: either a passphrase or
+// a 32-byte master key.
+type FileKey struct {
+	passphrase string
+	key        []byte
+	derived    *derivedFileKey
+}
+
+// derivedFileKey caches the last key derived from the passphrase of a
+// FileKey, so that reloading an unchanged file does not run PBKDF2 again.
+type derivedFileKey struct {
+	mu         sync.Mutex
+	salt       []byte
+	iterations int
+	key        []byte
+}
+
+// PassphraseFileKey returns a FileKey that derives the key encryption key
+// from passphrase with PBKDF2-SHA256. The key derived for the salt and
+// iteration count of the last file opened is cached.
+func PassphraseFileKey(passphrase string) FileKey {
+	return FileKey{passphrase: passphrase, derived: &derivedFileKey{}}
+}
+
+// MasterFileKey returns a FileKey that uses a 32-byte master key as the key
+// encryption key.
+func MasterFileKey(key []byte) FileKey {
+	return FileKey{key: key}
+}
+
+// encryptedCredentialFile is the JSON form of an encrypted credential file.
+// The credentials are encrypted with a random data key, which is encrypted
+// with the key encryption key, so that the file can be re-keyed without
+// re-encrypting the credentials.
+type encryptedCredentialFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt,omitempty"`
+	Iterations int    `json:"iterations,omitempty"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptCredentialFile encrypts the contents of a credential file, as read
+// by CredentialsFromFile, with key. Contents that would not load are
+// rejected.
+func EncryptCredentialFile(plaintext []byte, key FileKey) ([]byte, error) {
+	credentials, err := parseCredentialConfigs(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	for _, credential := range credentials {
+		credential.Zero()
+	}
+
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+
+	ciphertext, err := sealGCM(dataKey, plaintext, []byte("credentials"))
+	if err != nil {
+		return nil, err
+	}
+
+	file := &encryptedCredentialFile{Version: credentialFileVersion, Ciphertext: ciphertext}
+	if err := file.wrap(dataKey, key); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// DecryptCredentialFile decrypts a file encrypted with EncryptCredentialFile.
+func DecryptCredentialFile(data []byte, key FileKey) ([]byte, error) {
+	file, dataKey, err := openCredentialFile(data, key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := openGCM(dataKey, file.Ciphertext, []byte("credentials"))
+	if err != nil {
+		return nil, ErrCredentialFileKey
+	}
+
+	return plaintext, nil
+}
+
+// RekeyCredentialFile re-encrypts the data key of a file encrypted with
+// EncryptCredentialFile from oldKey to newKey.
+func RekeyCredentialFile(data []byte, oldKey FileKey, newKey FileKey) ([]byte, error) {
+	file, dataKey, err := openCredentialFile(data, oldKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := openGCM(dataKey, file.Ciphertext, []byte("credentials")); err != nil {
+		return nil, ErrCredentialFileKey
+	}
+
+	if err := file.wrap(dataKey, newKey); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// CredentialsFromEncryptedFile loads credentials from a file encrypted with
+// EncryptCredentialFile.
+func CredentialsFromEncryptedFile(path string, key FileKey) CredentialSource {
+	return func() ([]*Credential, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		plaintext, err := DecryptCredentialFile(data, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		return parseCredentialConfigs(plaintext)
+	}
+}
+
+func openCredentialFile(data []byte, key FileKey) (*encryptedCredentialFile, []byte, error) {
+	var file encryptedCredentialFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("malformed credential file: %w", err)
+	}
+	if file.Version != credentialFileVersion {
+		return nil, nil, fmt.Errorf("unsupported credential file version %d", file.Version)
+	}
+
+	kek, err := key.derive(&file, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataKey, err := openGCM(kek, file.WrappedKey, []byte("data key"))
+	if err != nil {
+		return nil, nil, ErrCredentialFileKey
+	}
+
+	return &file, dataKey, nil
+}
+
+// wrap encrypts dataKey with key and records how the key encryption key is
+// derived in f.
+func (f *encryptedCredentialFile) wrap(dataKey []byte, key FileKey) error {
+	kek, err := key.derive(f, true)
+	if err != nil {
+		return err
+	}
+
+	f.WrappedKey, err = sealGCM(kek, dataKey, []byte("data key"))
+	return err
+}
+
+// derive returns the key encryption key of f. With generate, it sets the
+// derivation parameters of f for key first.
+func (k FileKey) derive(f *encryptedCredentialFile, generate bool) ([]byte, error) {
+	if k.key != nil {
+		if len(k.key) != 32 {
+			return nil, fmt.Errorf("master key must be 32 bytes")
+		}
+		if generate {
+			f.KDF, f.Salt, f.Iterations = "none", nil, 0
+		}
+		if f.KDF != "none" {
+			return nil, fmt.Errorf("credential file requires a passphrase")
+		}
+		return k.key, nil
+	}
+
+	if k.passphrase == "" {
+		return nil, fmt.Errorf("passphrase required")
+	}
+	if generate {
+		f.KDF, f.Salt, f.Iterations = "pbkdf2-sha256", make([]byte, 16), pbkdf2Iterations
+		rand.Read(f.Salt)
+	}
+	if f.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("credential file requires a master key")
+	}
+	if f.Iterations < minPBKDF2Iterations || f.Iterations > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("unsupported PBKDF2 iteration count %d", f.Iterations)
+	}
+
+	if k.derived == nil {
+		return pbkdf2.Key(sha256.New, k.passphrase, f.Salt, f.Iterations, 32)
+	}
+
+	k.derived.mu.Lock()
+	defer k.derived.mu.Unlock()
+
+	if k.derived.key != nil && k.derived.iterations == f.Iterations && slices.Equal(k.derived.salt, f.Salt) {
+		return k.derived.key, nil
+	}
+	key, err := pbkdf2.Key(sha256.New, k.passphrase, f.Salt, f.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	k.derived.salt, k.derived.iterations, k.derived.key = slices.Clone(f.Salt), f.Iterations, key
+
+	return key, nil
+}
+
+func sealGCM(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func openGCM(key []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
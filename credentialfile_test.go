@@ -0,0 +1,132 @@
+package hmac
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testCredentialFile = `{"credentials": [{"id": "client-1", "secret": "00112233445566778899aabbccddeeff"}]}`
+
+func TestThatEncryptedCredentialFileRoundTrips(t *testing.T) {
+	for _, key := range []FileKey{PassphraseFileKey("correct horse"), MasterFileKey(bytes.Repeat([]byte{1}, 32))} {
+		encrypted, err := EncryptCredentialFile([]byte(testCredentialFile), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(encrypted, []byte("00112233")) {
+			t.Fatal("expected secret to be encrypted")
+		}
+
+		plaintext, err := DecryptCredentialFile(encrypted, key)
+		if err != nil || string(plaintext) != testCredentialFile {
+			t.Fatalf("unexpected plaintext %q, %v", plaintext, err)
+		}
+	}
+}
+
+func TestThatDecryptCredentialFileRejectsWrongKey(t *testing.T) {
+	encrypted, _ := EncryptCredentialFile([]byte(testCredentialFile), PassphraseFileKey("correct horse"))
+
+	_, err := DecryptCredentialFile(encrypted, PassphraseFileKey("battery staple"))
+	if !errors.Is(err, ErrCredentialFileKey) {
+		t.Fatalf("expected ErrCredentialFileKey, got %v", err)
+	}
+
+	if _, err := DecryptCredentialFile(encrypted, MasterFileKey(bytes.Repeat([]byte{1}, 32))); err == nil {
+		t.Fatal("expected error for master key on passphrase file")
+	}
+}
+
+func TestThatRekeyCredentialFileChangesKey(t *testing.T) {
+	oldKey := PassphraseFileKey("correct horse")
+	newKey := MasterFileKey(bytes.Repeat([]byte{2}, 32))
+	encrypted, _ := EncryptCredentialFile([]byte(testCredentialFile), oldKey)
+
+	rekeyed, err := RekeyCredentialFile(encrypted, oldKey, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecryptCredentialFile(rekeyed, oldKey); err == nil {
+		t.Fatal("expected old key to be rejected")
+	}
+	if plaintext, err := DecryptCredentialFile(rekeyed, newKey); err != nil || string(plaintext) != testCredentialFile {
+		t.Fatalf("unexpected plaintext %q, %v", plaintext, err)
+	}
+}
+
+func TestThatCredentialsFromEncryptedFileLoadsCredentials(t *testing.T) {
+	key := MasterFileKey(bytes.Repeat([]byte{3}, 32))
+	encrypted, _ := EncryptCredentialFile([]byte(testCredentialFile), key)
+	path := filepath.Join(t.TempDir(), "credentials.json.enc")
+	os.WriteFile(path, encrypted, 0600)
+
+	loader, err := NewCredentialLoader(CredentialsFromEncryptedFile(path, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := loader.Credential("client-1"); !ok {
+		t.Fatal("expected credential to be loaded")
+	}
+}
+
+func TestThatEncryptCredentialFileRejectsInvalidCredentials(t *testing.T) {
+	for _, plaintext := range []string{`not json`, `{"credentials": [{"id": "client-1", "secret": "short"}]}`} {
+		if _, err := EncryptCredentialFile([]byte(plaintext), MasterFileKey(bytes.Repeat([]byte{1}, 32))); err == nil {
+			t.Fatalf("expected %s to be rejected", plaintext)
+		}
+	}
+}
+
+func TestThatDecryptCredentialFileRejectsUnsupportedIterationCounts(t *testing.T) {
+	key := PassphraseFileKey("correct horse")
+	encrypted, _ := EncryptCredentialFile([]byte(testCredentialFile), key)
+
+	for _, iterations := range []int{1, 1000000000} {
+		var file encryptedCredentialFile
+		json.Unmarshal(encrypted, &file)
+		file.Iterations = iterations
+		modified, _ := json.Marshal(file)
+
+		if _, err := DecryptCredentialFile(modified, key); err == nil || errors.Is(err, ErrCredentialFileKey) {
+			t.Fatalf("expected %d iterations to be rejected, got %v", iterations, err)
+		}
+	}
+}
+
+func TestThatPassphraseFileKeyCachesDerivedKey(t *testing.T) {
+	key := PassphraseFileKey("correct horse")
+	encrypted, _ := EncryptCredentialFile([]byte(testCredentialFile), key)
+	path := filepath.Join(t.TempDir(), "credentials.json.enc")
+	os.WriteFile(path, encrypted, 0600)
+
+	loader, err := NewCredentialLoader(CredentialsFromEncryptedFile(path, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	derived := key.derived.key
+	if derived == nil {
+		t.Fatal("expected derived key to be cached")
+	}
+
+	if err := loader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if &key.derived.key[0] != &derived[0] {
+		t.Fatal("expected reload to reuse the derived key")
+	}
+
+	rekeyed, _ := RekeyCredentialFile(encrypted, key, key)
+	os.WriteFile(path, rekeyed, 0600)
+	if err := loader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if &key.derived.key[0] == &derived[0] {
+		t.Fatal("expected a new salt to derive a new key")
+	}
+}
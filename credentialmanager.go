@@ -0,0 +1,332 @@
+package hmac
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRotationOverlap is how long the previous secret of a rotated
+// credential remains valid when no overlap is given.
+const DefaultRotationOverlap = 24 * time.Hour
+
+var (
+	// ErrCredentialNotFound is returned for operations on an unknown
+	// credential.
+	ErrCredentialNotFound = errors.New("credential not found")
+	// ErrCredentialExists is returned when creating a credential whose ID is
+	// already in use.
+	ErrCredentialExists = errors.New("credential already exists")
+	// ErrCredentialRevoked is returned when rotating a revoked credential.
+	ErrCredentialRevoked = errors.New("credential revoked")
+	// ErrInvalidCredentialID is returned when creating a credential whose ID
+	// contains a "/", which the admin API cannot address.
+	ErrInvalidCredentialID = errors.New("invalid credential ID")
+)
+
+// ManagedCredential describes a credential of a CredentialManager. Its
+// secret is only returned when it is created or rotated.
+type ManagedCredential struct {
+	ID        string            `json:"id"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	RotatedAt time.Time         `json:"rotated_at,omitzero"`
+	// PreviousExpiresAt is the end of the overlap during which the secret
+	// replaced by the last rotation remains valid.
+	PreviousExpiresAt time.Time `json:"previous_expires_at,omitzero"`
+	ExpiresAt         time.Time `json:"expires_at,omitzero"`
+	Revoked           bool      `json:"revoked"`
+	LastUsedAt        time.Time `json:"last_used_at,omitzero"`
+}
+
+// CredentialUsage counts the validations of requests signed by a credential.
+// Requests with an invalid signature are not counted, since they may not come
+// from the credential; Rejected counts validly signed requests rejected
+// afterwards, for example because the credential is revoked.
+type CredentialUsage struct {
+	Requests int64 `json:"requests"`
+	Rejected int64 `json:"rejected"`
+	// Reasons counts rejected requests by reason.
+	Reasons map[Reason]int64 `json:"reasons,omitempty"`
+	// PreviousSecretRequests counts requests signed with the previous secret
+	// since the last rotation, to tell when clients have switched.
+	PreviousSecretRequests int64     `json:"previous_secret_requests"`
+	LastUsedAt             time.Time `json:"last_used_at,omitzero"`
+	LastRejectedAt         time.Time `json:"last_rejected_at,omitzero"`
+}
+
+// CredentialManager is an in-memory CredentialStore whose credentials can be
+// created, rotated and revoked at runtime, see NewAdminHandler. It is also an
+// Observer recording the usage of its credentials; pass it to WithObserver
+// of the Authenticator using it. It is safe for concurrent use.
+type CredentialManager struct {
+	mu          sync.RWMutex
+	credentials map[string]*managedCredential
+	clock       func() time.Time
+}
+
+type managedCredential struct {
+	info     ManagedCredential
+	verifier *rotatingVerifier
+	usage    CredentialUsage
+}
+
+type CredentialManagerOption func(*CredentialManager)
+
+// WithCredentialManagerClock sets the clock used for creation, rotation and
+// usage times. It defaults to time.Now.
+func WithCredentialManagerClock(clock func() time.Time) CredentialManagerOption {
+	return func(m *CredentialManager) {
+		m.clock = clock
+	}
+}
+
+// NewCredentialManager returns an empty CredentialManager.
+func NewCredentialManager(options ...CredentialManagerOption) *CredentialManager {
+	m := &CredentialManager{
+		credentials: make(map[string]*managedCredential),
+		clock:       time.Now,
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
+
+// Create creates a credential with a secret generated by
+// GenerateSecureRandom and returns it with its hex encoded secret. If id is
+// empty, a random ID is generated. A zero expiresAt never expires.
+func (m *CredentialManager) Create(id string, metadata map[string]string, expiresAt time.Time) (ManagedCredential, string, error) {
+	if id == "" {
+		id = GenerateSecureRandom(16)
+	}
+	if strings.Contains(id, "/") {
+		return ManagedCredential{}, "", fmt.Errorf("%w: %s", ErrInvalidCredentialID, id)
+	}
+	secret, key := newManagedSecret()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.credentials[id]; ok {
+		return ManagedCredential{}, "", fmt.Errorf("%w: %s", ErrCredentialExists, id)
+	}
+
+	c := &managedCredential{
+		info: ManagedCredential{
+			ID:        id,
+			Metadata:  maps.Clone(metadata),
+			CreatedAt: m.clock(),
+			ExpiresAt: expiresAt,
+		},
+		verifier: &rotatingVerifier{current: key},
+	}
+	m.credentials[id] = c
+
+	return c.snapshot(), secret, nil
+}
+
+// Rotate replaces the secret of a credential with a new one, which it
+// returns hex encoded. The previous secret remains valid for overlap, so
+// that clients can switch without failed requests; a secret replaced by an
+// earlier rotation is no longer valid. Replaced secrets are not zeroed, since
+// requests being validated may still read them, and are left to the garbage
+// collector.
+func (m *CredentialManager) Rotate(id string, overlap time.Duration) (ManagedCredential, string, error) {
+	secret, key := newManagedSecret()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.credentials[id]
+	if !ok {
+		return ManagedCredential{}, "", fmt.Errorf("%w: %s", ErrCredentialNotFound, id)
+	}
+	if c.info.Revoked {
+		return ManagedCredential{}, "", fmt.Errorf("%w: %s", ErrCredentialRevoked, id)
+	}
+
+	now := m.clock()
+	c.verifier = &rotatingVerifier{
+		current:       key,
+		previous:      c.verifier.current,
+		previousUntil: now.Add(overlap),
+		clock:         m.clock,
+	}
+	c.info.RotatedAt = now
+	c.info.PreviousExpiresAt = now.Add(overlap)
+
+	return c.snapshot(), secret, nil
+}
+
+// Revoke revokes a credential. Requests it signs are rejected with
+// ReasonCredentialRevoked.
+func (m *CredentialManager) Revoke(id string) (ManagedCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.credentials[id]
+	if !ok {
+		return ManagedCredential{}, fmt.Errorf("%w: %s", ErrCredentialNotFound, id)
+	}
+	c.info.Revoked = true
+
+	return c.snapshot(), nil
+}
+
+// Get returns the credential with the given ID.
+func (m *CredentialManager) Get(id string) (ManagedCredential, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.credentials[id]
+	if !ok {
+		return ManagedCredential{}, false
+	}
+
+	return c.snapshot(), true
+}
+
+// List returns all credentials ordered by ID.
+func (m *CredentialManager) List() []ManagedCredential {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	credentials := make([]ManagedCredential, 0, len(m.credentials))
+	for _, c := range m.credentials {
+		credentials = append(credentials, c.snapshot())
+	}
+	slices.SortFunc(credentials, func(a, b ManagedCredential) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return credentials
+}
+
+// Usage returns the usage of the credential with the given ID.
+func (m *CredentialManager) Usage(id string) (CredentialUsage, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.credentials[id]
+	if !ok {
+		return CredentialUsage{}, false
+	}
+
+	usage := c.usage
+	usage.Reasons = maps.Clone(c.usage.Reasons)
+	usage.PreviousSecretRequests = c.verifier.previousUses.Load()
+
+	return usage, true
+}
+
+// Credential implements CredentialStore.
+func (m *CredentialManager) Credential(id string) (*Credential, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.credentials[id]
+	if !ok {
+		return nil, false
+	}
+
+	return &Credential{
+		ID:        c.info.ID,
+		Verifier:  c.verifier,
+		Metadata:  maps.Clone(c.info.Metadata),
+		ExpiresAt: c.info.ExpiresAt,
+		Revoked:   c.info.Revoked,
+	}, true
+}
+
+// ObserveValidation implements Observer by recording the usage of the
+// credential that signed the request, if it is managed by m.
+func (m *CredentialManager) ObserveValidation(event ValidationEvent) {
+	if event.Credential == "" {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.credentials[event.Credential]
+	if !ok {
+		return
+	}
+
+	now := m.clock()
+	if event.Reason == "" {
+		c.usage.Requests++
+		c.usage.LastUsedAt = now
+		c.info.LastUsedAt = now
+		return
+	}
+
+	c.usage.Rejected++
+	c.usage.LastRejectedAt = now
+	if c.usage.Reasons == nil {
+		c.usage.Reasons = make(map[Reason]int64)
+	}
+	c.usage.Reasons[event.Reason]++
+}
+
+// ObserveSigning implements Observer.
+func (m *CredentialManager) ObserveSigning(SigningEvent) {}
+
+func (c *managedCredential) snapshot() ManagedCredential {
+	info := c.info
+	info.Metadata = maps.Clone(c.info.Metadata)
+	return info
+}
+
+// newManagedSecret returns a new hex encoded secret and its key.
+func newManagedSecret() (string, SecretKey) {
+	secret := GenerateSecureRandom(32)
+	key, err := ParseSecretKey(secret)
+	if err != nil {
+		panic("hmac: generated invalid secret: " + err.Error())
+	}
+
+	return secret, key
+}
+
+// rotatingVerifier verifies signatures made with the current secret of a
+// managed credential, or with its previous secret until previousUntil.
+type rotatingVerifier struct {
+	current       SecretKey
+	previous      SecretKey
+	previousUntil time.Time
+	clock         func() time.Time
+	previousUses  atomic.Int64
+}
+
+// Sign implements Signer with the current secret, so that Diagnose can
+// explain signatures.
+func (v *rotatingVerifier) Sign(canonicalRequest string, timestamp int64, scope string) (string, error) {
+	return v.current.Sign(canonicalRequest, timestamp, scope)
+}
+
+// Verify implements Verifier.
+func (v *rotatingVerifier) Verify(canonicalRequest string, timestamp int64, scope string, signature string) (bool, error) {
+	if valid, err := v.current.Verify(canonicalRequest, timestamp, scope, signature); valid || err != nil {
+		return valid, err
+	}
+
+	if v.clock == nil || !v.clock().Before(v.previousUntil) {
+		return false, nil
+	}
+
+	valid, err := v.previous.Verify(canonicalRequest, timestamp, scope, signature)
+	if valid {
+		v.previousUses.Add(1)
+	}
+
+	return valid, err
+}
@@ -0,0 +1,131 @@
+package hmac
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestThatManagedCredentialSignsRequests(t *testing.T) {
+	manager := NewCredentialManager()
+	credential, secret, err := manager.Create("", map[string]string{"team": "orders"}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300,
+		WithCredentialStore(manager), WithObserver(manager))
+
+	result, err := authenticator.Authenticate(signedTestRequest(t, credential.ID, secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Metadata["team"] != "orders" {
+		t.Fatalf("unexpected metadata %v", result.Metadata)
+	}
+
+	if _, _, err := manager.Create(credential.ID, nil, time.Time{}); !errors.Is(err, ErrCredentialExists) {
+		t.Fatalf("expected ErrCredentialExists, got %v", err)
+	}
+}
+
+func TestThatRotatedSecretRemainsValidDuringOverlap(t *testing.T) {
+	now := time.Now()
+	manager := NewCredentialManager(WithCredentialManagerClock(func() time.Time { return now }))
+	credential, first, _ := manager.Create("client", nil, time.Time{})
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300,
+		WithCredentialStore(manager), WithObserver(manager))
+
+	_, second, err := manager.Rotate(credential.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{first, second} {
+		if _, err := authenticator.Authenticate(signedTestRequest(t, credential.ID, secret)); err != nil {
+			t.Fatalf("expected secret to be valid during overlap, got %v", err)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	_, err = authenticator.Authenticate(signedTestRequest(t, credential.ID, first))
+	assertValidationError(t, err, "Not authorized")
+	if _, err := authenticator.Authenticate(signedTestRequest(t, credential.ID, second)); err != nil {
+		t.Fatalf("expected new secret to be valid, got %v", err)
+	}
+
+	usage, _ := manager.Usage(credential.ID)
+	if usage.Requests != 3 || usage.Rejected != 0 || usage.PreviousSecretRequests != 1 || !usage.LastUsedAt.Equal(now) {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+	if listed := manager.List(); len(listed) != 1 || !listed[0].LastUsedAt.Equal(now) || !listed[0].PreviousExpiresAt.Equal(now) {
+		t.Fatalf("unexpected credentials %+v", listed)
+	}
+}
+
+func TestThatRevokedManagedCredentialIsRejected(t *testing.T) {
+	manager := NewCredentialManager()
+	credential, secret, _ := manager.Create("client", nil, time.Time{})
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300,
+		WithCredentialStore(manager), WithObserver(manager))
+
+	if _, err := manager.Revoke(credential.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := authenticator.Authenticate(signedTestRequest(t, credential.ID, secret))
+	assertValidationError(t, err, "Credential revoked")
+	if usage, _ := manager.Usage(credential.ID); usage.Rejected != 1 || usage.Reasons[ReasonCredentialRevoked] != 1 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+	if _, _, err := manager.Rotate(credential.ID, time.Hour); !errors.Is(err, ErrCredentialRevoked) {
+		t.Fatalf("expected ErrCredentialRevoked, got %v", err)
+	}
+	if _, err := manager.Revoke("unknown"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("expected ErrCredentialNotFound, got %v", err)
+	}
+}
+
+func TestThatManagedCredentialCanBeDiagnosed(t *testing.T) {
+	manager := NewCredentialManager()
+	credential, secret, _ := manager.Create("client", nil, time.Time{})
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300, WithCredentialStore(manager))
+
+	diagnosis := authenticator.Diagnose(signedTestRequest(t, credential.ID, secret), time.Now())
+	if !diagnosis.OK() || diagnosis.Explanation == nil || !diagnosis.Explanation.SignatureMatches() {
+		t.Fatalf("unexpected diagnosis %+v", diagnosis)
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+	if diagnosis := authenticator.Diagnose(request, time.Now()); diagnosis.OK() {
+		t.Fatal("expected unsigned request to fail diagnosis")
+	}
+}
+
+func TestThatRotationKeepsSecretsOfInFlightVerifiers(t *testing.T) {
+	manager := NewCredentialManager()
+	manager.Create("client", nil, time.Time{})
+	first := manager.credentials["client"].verifier.current
+	firstBytes := bytes.Clone(first.Bytes())
+	inFlight, _ := manager.Credential("client")
+
+	manager.Rotate("client", time.Hour)
+	manager.Rotate("client", time.Hour)
+
+	if !bytes.Equal(first.Bytes(), firstBytes) {
+		t.Fatal("expected dropped secret to be left intact")
+	}
+	timestamp := time.Now().Unix()
+	signature, _ := first.Sign("request", timestamp, "")
+	if valid, err := inFlight.Verifier.Verify("request", timestamp, "", signature); !valid || err != nil {
+		t.Fatalf("expected verifier loaded before rotation to keep working, got %v, %v", valid, err)
+	}
+	if current, _ := manager.Credential("client"); mustVerify(current, "request", timestamp, signature) {
+		t.Fatal("expected dropped secret to be rejected by the current verifier")
+	}
+}
+
+func mustVerify(credential *Credential, canonicalRequest string, timestamp int64, signature string) bool {
+	valid, _ := credential.Verifier.Verify(canonicalRequest, timestamp, "", signature)
+	return valid
+}
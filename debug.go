@@ -0,0 +1,150 @@
+package hmac
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// Explanation is a breakdown of each step of signing a request. Clients can
+// compare it with their own implementation to find where they diverge.
+type Explanation struct {
+	Method    string
+	Authority string
+	Path      string
+	Query     string
+	Timestamp int64
+	Nonce     string
+
+	// BodySize is the length of the request body in bytes.
+	BodySize int
+	// ContentHash is the base64 SHA-256 hash of the request body, or empty
+	// when the request has no body.
+	ContentHash string
+	// ProvidedContentHash is the value of the X-Content-SHA256 header.
+	ProvidedContentHash string
+
+	SignedHeaders        map[string]string
+	CanonicalRequest     string
+	CanonicalRequestHash string
+	StringToSign         string
+
+	// Signature is the expected value of the Signature header.
+	Signature string
+	// ProvidedSignature is the value of the Signature header.
+	ProvidedSignature string
+}
+
+// ContentHashMatches reports whether the X-Content-SHA256 header matches
+// the request body.
+func (e *Explanation) ContentHashMatches() bool {
+	return e.ContentHash == e.ProvidedContentHash
+}
+
+// SignatureMatches reports whether the Signature header matches the
+// expected signature.
+func (e *Explanation) SignatureMatches() bool {
+	return hmac.Equal([]byte(e.Signature), []byte(e.ProvidedSignature))
+}
+
+// LogValue implements slog.LogValuer. The expected and provided signatures
+// are never included.
+func (e *Explanation) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("method", e.Method),
+		slog.String("authority", e.Authority),
+		slog.String("path", e.Path),
+		slog.String("query", e.Query),
+		slog.Int64("timestamp", e.Timestamp),
+		slog.String("nonce", e.Nonce),
+		slog.Int("body_size", e.BodySize),
+		slog.String("content_hash", e.ContentHash),
+		slog.String("provided_content_hash", e.ProvidedContentHash),
+		slog.String("canonical_request", e.CanonicalRequest),
+		slog.String("string_to_sign", e.StringToSign),
+		slog.Bool("signature_matches", e.SignatureMatches()),
+	)
+}
+
+// Explain recomputes the signature of a signed request with the private key,
+// parsed with ParseSecretKey, and returns every intermediate value. The
+// X-Timestamp and X-Nonce headers of the request are used as is. The request
+// body is restored so it can still be read afterwards.
+func Explain(request *http.Request, private string) (*Explanation, error) {
+	key, err := ParseSecretKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	return explain(request, privateKeySigner(key.Bytes(), ""))
+}
+
+// explain is Explain with the signatures computed by sign.
+func explain(request *http.Request, sign func(canonicalRequest string, timestamp int64, scope string) (string, error)) (*Explanation, error) {
+	timestamp, err := strconv.ParseInt(request.Header.Get("X-Timestamp"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %w", err)
+	}
+
+	var content []byte
+	if request.Body != nil {
+		content, err = io.ReadAll(request.Body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read request body: %w", err)
+		}
+		request.Body = io.NopCloser(bytes.NewReader(content))
+	}
+
+	e := &Explanation{
+		Method:              request.Method,
+		Authority:           request.Host,
+		Path:                request.URL.Path,
+		Query:               request.URL.RawQuery,
+		Timestamp:           timestamp,
+		Nonce:               request.Header.Get("X-Nonce"),
+		BodySize:            len(content),
+		ProvidedContentHash: request.Header.Get("X-Content-SHA256"),
+		ProvidedSignature:   request.Header.Get("Signature"),
+	}
+
+	if len(content) > 0 {
+		contentHash := sha256.Sum256(content)
+		e.ContentHash = base64.StdEncoding.EncodeToString(contentHash[:])
+	}
+
+	e.SignedHeaders = make(map[string]string)
+	e.SignedHeaders["X-Timestamp"] = strconv.FormatInt(timestamp, 10)
+	e.SignedHeaders["X-Nonce"] = e.Nonce
+	if e.ProvidedContentHash != "" {
+		e.SignedHeaders["X-Content-SHA256"] = e.ProvidedContentHash
+	}
+	if scope := request.Header.Get("X-Scope"); scope != "" {
+		e.SignedHeaders["X-Scope"] = scope
+	}
+	addSignedHeaders(e.SignedHeaders, request.Header)
+
+	e.CanonicalRequest = CreateCanonicalRequestString(e.Method, e.Authority, e.Path, e.Query, e.SignedHeaders)
+	canonicalRequestHash := sha256.Sum256([]byte(e.CanonicalRequest))
+	e.CanonicalRequestHash = base64.StdEncoding.EncodeToString(canonicalRequestHash[:])
+	e.StringToSign = CreateStringToSign(e.CanonicalRequest, timestamp)
+	e.Signature, err = sign(e.CanonicalRequest, timestamp, e.SignedHeaders["X-Scope"])
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign request: %w", err)
+	}
+
+	return e, nil
+}
+
+// privateKeySigner returns the signatures of a decoded private key.
+// credentialScope is the Scope of the credential the key belongs to.
+func privateKeySigner(decodedPrivateKey []byte, credentialScope string) func(string, int64, string) (string, error) {
+	return func(canonicalRequest string, timestamp int64, scope string) (string, error) {
+		return createSignature(canonicalRequest, timestamp, decodedPrivateKey, credentialScope, scope), nil
+	}
+}
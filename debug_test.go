@@ -0,0 +1,90 @@
+package hmac
+
+import (
+	"encoding/base64"
+	"io"
+	"testing"
+)
+
+func TestThatExplainMatchesSignedRequest(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+
+	explanation, err := Explain(signedRequest, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !explanation.SignatureMatches() {
+		t.Fatalf("expected signature %q, got %q", explanation.Signature, explanation.ProvidedSignature)
+	}
+	if !explanation.ContentHashMatches() {
+		t.Fatalf("expected content hash %q, got %q", explanation.ContentHash, explanation.ProvidedContentHash)
+	}
+	if explanation.StringToSign != CreateStringToSign(explanation.CanonicalRequest, explanation.Timestamp) {
+		t.Fatal("expected string to sign to be derived from canonical request")
+	}
+
+	body, _ := io.ReadAll(signedRequest.Body)
+	if string(body) != `{"foo": "bar"}` {
+		t.Fatalf("expected body to be restored, got %q", string(body))
+	}
+}
+
+func TestThatExplainReportsMismatchForTamperedRequest(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	signedRequest.URL.RawQuery = "abc=tampered"
+
+	explanation, err := Explain(signedRequest, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if explanation.SignatureMatches() {
+		t.Fatal("expected signature mismatch")
+	}
+	if explanation.Query != "abc=tampered" {
+		t.Fatalf("expected query %q, got %q", "abc=tampered", explanation.Query)
+	}
+}
+
+func TestThatExplainReturnsErrorInvalidTimestamp(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	signedRequest.Header.Del("X-Timestamp")
+
+	if _, err := Explain(signedRequest, privateKey); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestThatExplainAcceptsBase64PrivateKeys(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	key, _ := ParseSecretKey(GenerateSecureRandom(16))
+	privateKey := base64.StdEncoding.EncodeToString(key.Bytes())
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+
+	explanation, err := Explain(signedRequest, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !explanation.SignatureMatches() {
+		t.Fatalf("expected signature %q, got %q", explanation.Signature, explanation.ProvidedSignature)
+	}
+}
+
+func TestThatExplainRejectsMalformedPrivateKeys(t *testing.T) {
+	signedRequest := signedTestRequest(t, GenerateSecureRandom(16), GenerateSecureRandom(16))
+
+	if _, err := Explain(signedRequest, "not a key!"); err == nil || err.Error() != "malformed private key" {
+		t.Fatalf("expected malformed private key error, got %v", err)
+	}
+}
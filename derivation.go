@@ -0,0 +1,161 @@
+package hmac
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// MasterKey is a server secret from which credential keys are derived.
+// Generation identifies the key so that it can be rotated.
+type MasterKey struct {
+	Generation string
+	Secret     []byte
+}
+
+// KeyDeriver derives the private key of a credential from a master key and
+// the client identifier with HKDF-SHA256, so that servers can validate any
+// client without storing per-client secrets. Derived credential IDs have the
+// form "<generation>.<client>", which selects the master key to derive the
+// private key from.
+//
+// KeyDeriver implements CredentialStore.
+type KeyDeriver struct {
+	current string
+	masters map[string][]byte
+	context string
+}
+
+// Format implements fmt.Formatter, so that no verb prints the secret.
+func (m MasterKey) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, m.String())
+}
+
+// String returns the generation of the key and a placeholder for its secret.
+func (m MasterKey) String() string {
+	return "{" + m.Generation + " " + redacted + "}"
+}
+
+// LogValue implements slog.LogValuer. The secret is never included.
+func (m MasterKey) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("generation", m.Generation))
+}
+
+type KeyDeriverOption func(*KeyDeriver)
+
+// WithDerivationContext binds derived keys to context, such as a tenant or
+// environment name, so that the same client identifier derives different
+// keys in different contexts.
+func WithDerivationContext(context string) KeyDeriverOption {
+	return func(d *KeyDeriver) {
+		d.context = context
+	}
+}
+
+// minMasterKeyLength is the minimum length of a master secret in bytes.
+const minMasterKeyLength = 32
+
+// NewKeyDeriver returns a KeyDeriver that issues credentials with current
+// and accepts credentials derived from current or any of previous. To rotate
+// master keys, make the new key current and pass the old one in previous
+// until its credentials are reissued, then drop it.
+func NewKeyDeriver(current MasterKey, previous []MasterKey, options ...KeyDeriverOption) (*KeyDeriver, error) {
+	d := &KeyDeriver{
+		current: current.Generation,
+		masters: make(map[string][]byte),
+	}
+
+	for _, master := range append([]MasterKey{current}, previous...) {
+		if len(master.Generation) == 0 || strings.Contains(master.Generation, ".") {
+			return nil, fmt.Errorf("invalid master key generation %q", master.Generation)
+		}
+		if len(master.Secret) < minMasterKeyLength {
+			return nil, fmt.Errorf("master key %s must be at least %d bytes", master.Generation, minMasterKeyLength)
+		}
+		if _, ok := d.masters[master.Generation]; ok {
+			return nil, fmt.Errorf("duplicate master key generation %s", master.Generation)
+		}
+		d.masters[master.Generation] = master.Secret
+	}
+
+	for _, option := range options {
+		option(d)
+	}
+
+	return d, nil
+}
+
+// Format implements fmt.Formatter, so that no verb prints the master
+// secrets.
+func (d *KeyDeriver) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, d.String())
+}
+
+// String returns the generations of the master keys, never their secrets.
+func (d *KeyDeriver) String() string {
+	return fmt.Sprintf("KeyDeriver{current: %s, generations: %s}", d.current, strings.Join(d.generations(), ","))
+}
+
+// LogValue implements slog.LogValuer. The master secrets are never included.
+func (d *KeyDeriver) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("current", d.current),
+		slog.Any("generations", d.generations()),
+	)
+}
+
+func (d *KeyDeriver) generations() []string {
+	return slices.Sorted(maps.Keys(d.masters))
+}
+
+// Derive issues the credential of client with the current master key.
+// Clients sign with credential.ID as the public key and
+// hex.EncodeToString(credential.Private) as the private key.
+func (d *KeyDeriver) Derive(client string) (*Credential, error) {
+	if len(client) == 0 {
+		return nil, fmt.Errorf("client required")
+	}
+
+	return d.derive(d.current, client)
+}
+
+// Credential implements CredentialStore.
+func (d *KeyDeriver) Credential(id string) (*Credential, bool) {
+	generation, client, ok := strings.Cut(id, ".")
+	if !ok || len(client) == 0 {
+		return nil, false
+	}
+
+	if _, ok := d.masters[generation]; !ok {
+		return nil, false
+	}
+
+	credential, err := d.derive(generation, client)
+	if err != nil {
+		return nil, false
+	}
+
+	return credential, true
+}
+
+func (d *KeyDeriver) derive(generation string, client string) (*Credential, error) {
+	info := "github.com/pascalallen/hmac/v2 credential\x00" + d.context + "\x00" + client
+	private, err := hkdf.Key(sha256.New, d.masters[generation], nil, info, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:      generation + "." + client,
+		Private: private,
+		Metadata: map[string]string{
+			"client":     client,
+			"generation": generation,
+		},
+	}, nil
+}
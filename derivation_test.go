@@ -0,0 +1,118 @@
+package hmac
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func testMasterKey(generation string) MasterKey {
+	return MasterKey{Generation: generation, Secret: bytes.Repeat([]byte(generation), 32)}
+}
+
+func TestThatNewKeyDeriverRejectsShortMasterKey(t *testing.T) {
+	errMsg := "master key v1 must be at least 32 bytes"
+
+	deriver, err := NewKeyDeriver(MasterKey{Generation: "v1", Secret: []byte("short")}, nil)
+
+	if deriver != nil || err == nil || err.Error() != errMsg {
+		t.Fatal(err)
+	}
+}
+
+func TestThatNewKeyDeriverRejectsInvalidGeneration(t *testing.T) {
+	if _, err := NewKeyDeriver(testMasterKey("v.1"), nil); err == nil {
+		t.Fatal("expected error for generation containing a dot")
+	}
+	if _, err := NewKeyDeriver(testMasterKey("v1"), []MasterKey{testMasterKey("v1")}); err == nil {
+		t.Fatal("expected error for duplicate generation")
+	}
+}
+
+func TestThatDeriveIsDeterministic(t *testing.T) {
+	deriver, _ := NewKeyDeriver(testMasterKey("v1"), nil)
+
+	first, _ := deriver.Derive("client-123")
+	second, _ := deriver.Derive("client-123")
+	other, _ := deriver.Derive("client-456")
+
+	if first.ID != "v1.client-123" || !bytes.Equal(first.Private, second.Private) || bytes.Equal(first.Private, other.Private) {
+		t.Fatalf("unexpected credentials %v, %v, %v", first, second, other)
+	}
+}
+
+func TestThatDerivationContextChangesKeys(t *testing.T) {
+	production, _ := NewKeyDeriver(testMasterKey("v1"), nil, WithDerivationContext("production"))
+	staging, _ := NewKeyDeriver(testMasterKey("v1"), nil, WithDerivationContext("staging"))
+
+	a, _ := production.Derive("client-123")
+	b, _ := staging.Derive("client-123")
+
+	if bytes.Equal(a.Private, b.Private) {
+		t.Fatal("expected different keys in different contexts")
+	}
+}
+
+func TestThatAuthenticatorAcceptsDerivedCredentialsAcrossGenerations(t *testing.T) {
+	old, _ := NewKeyDeriver(testMasterKey("v1"), nil)
+	issued, _ := old.Derive("client-123")
+
+	rotated, _ := NewKeyDeriver(testMasterKey("v2"), []MasterKey{testMasterKey("v1")})
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300, WithCredentialStore(rotated))
+
+	request := signedTestRequest(t, issued.ID, hex.EncodeToString(issued.Private))
+	result, err := authenticator.Authenticate(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Metadata["client"] != "client-123" || result.Metadata["generation"] != "v1" {
+		t.Fatalf("unexpected metadata %v", result.Metadata)
+	}
+
+	retired, _ := NewKeyDeriver(testMasterKey("v2"), nil)
+	authenticator, _ = NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300, WithCredentialStore(retired))
+	_, err = authenticator.Authenticate(signedTestRequest(t, issued.ID, hex.EncodeToString(issued.Private)))
+	assertValidationError(t, err, "Not authorized")
+}
+
+func TestThatKeyDeriverRejectsUnknownCredentialIDs(t *testing.T) {
+	deriver, _ := NewKeyDeriver(testMasterKey("v1"), nil)
+
+	for _, id := range []string{"client-123", "v2.client-123", "v1."} {
+		if _, ok := deriver.Credential(id); ok {
+			t.Errorf("expected %q to be unknown", id)
+		}
+	}
+}
+
+func TestThatKeyDeriverFormattingOmitsMasterSecrets(t *testing.T) {
+	current := testMasterKey("g2")
+	deriver, _ := NewKeyDeriver(current, []MasterKey{testMasterKey("g1")})
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("deriver", "deriver", deriver, "master", current)
+	fmt.Fprintf(&buf, "%v %+v %#v %s %v %+v", deriver, deriver, deriver, deriver, current, []MasterKey{current})
+
+	if strings.Contains(buf.String(), string(current.Secret[:8])) || strings.Contains(buf.String(), fmt.Sprint(current.Secret[:8])) {
+		t.Fatalf("expected no master secret in output, got %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "g1") {
+		t.Fatalf("expected generations in output, got %s", buf.String())
+	}
+}
+
+func TestThatInventedDerivedIDsAreNotReportedToObserver(t *testing.T) {
+	deriver, _ := NewKeyDeriver(testMasterKey("g1"), nil)
+	observer := &recordingObserver{}
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300,
+		WithCredentialStore(deriver), WithObserver(observer))
+
+	authenticator.Validate(signedTestRequest(t, "g1."+GenerateSecureRandom(8), GenerateSecureRandom(16)))
+
+	if event := observer.validations[0]; event.Reason != ReasonInvalidSignature || event.Credential != "" {
+		t.Fatalf("unexpected event for invented credential: %+v", event)
+	}
+}
@@ -0,0 +1,169 @@
+package hmac
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DiagnosisStep is the outcome of one step of validating a request.
+type DiagnosisStep struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// Diagnosis reports every step of validating a recorded request. Unlike
+// Authenticate, which stops at the first failure, it runs every step that
+// can be run, so that support can see everything wrong with a request at
+// once.
+type Diagnosis struct {
+	// At is the time the request was validated at.
+	At    time.Time
+	Steps []DiagnosisStep
+	// Explanation is the signing breakdown computed with the credential's
+	// key. It is nil when the credential is unknown or the timestamp is
+	// invalid.
+	Explanation *Explanation
+	// Result and Err are the outcome of Authenticate at time At.
+	Result *Result
+	Err    error
+}
+
+// Diagnose validates a recorded request as if it was received at time at,
+// typically the recorded time of the request. The nonce store, observer,
+// logger and audit sink of the Authenticator are not used. The request body
+// is restored so it can still be read afterwards.
+func (a *Authenticator) Diagnose(r *http.Request, at time.Time) *Diagnosis {
+	d := &Diagnosis{At: at}
+
+	// The steps below read the signature headers under their default names.
+	original := r
+	normalized := *r
+	normalized.Header = a.headerNames.toDefault(r.Header)
+	r = &normalized
+	defer func() {
+		original.Body = normalized.Body
+	}()
+
+	var missing []string
+	for _, h := range requiredHeaders {
+		if r.Header.Get(h) == "" {
+			missing = append(missing, h)
+		}
+	}
+	if len(missing) > 0 {
+		d.step("required headers", false, "missing "+strings.Join(missing, ", "))
+	} else {
+		d.step("required headers", true, "all present")
+	}
+
+	timestamp, err := strconv.ParseInt(r.Header.Get("X-Timestamp"), 10, 64)
+	if err != nil {
+		d.step("timestamp", false, fmt.Sprintf("invalid X-Timestamp %q", r.Header.Get("X-Timestamp")))
+	} else {
+		skew := timestamp - at.Unix()
+		d.step("timestamp", skew >= -a.timeTolerance && skew <= a.timeTolerance,
+			fmt.Sprintf("%s, skew %ds (tolerance %ds)", time.Unix(timestamp, 0).UTC().Format(time.RFC3339), skew, a.timeTolerance))
+	}
+
+	credential, ok := a.credential(r.Header.Get("Credential"))
+	if !ok {
+		d.step("credential", false, fmt.Sprintf("unknown credential %q", r.Header.Get("Credential")))
+	} else {
+		d.step("credential", true, credential.ID)
+		if statusErr := a.checkStatus(credential, at); statusErr != nil {
+			d.step("credential status", false, statusErr.Message)
+		}
+	}
+
+	if ok && err == nil {
+		if scopeErr := a.checkScope(r.Header.Get("X-Scope"), timestamp, credential); scopeErr != nil {
+			d.step("scope", false, fmt.Sprintf("%s: %q", scopeErr.Message, r.Header.Get("X-Scope")))
+		} else if r.Header.Get("X-Scope") != "" {
+			d.step("scope", true, r.Header.Get("X-Scope"))
+		}
+
+		if credential.Policy != nil {
+			if policyErr := credential.Policy.Allow(r, at); policyErr != nil {
+				d.step("policy", false, policyErr.Error())
+			} else {
+				d.step("policy", true, "allowed")
+			}
+		}
+
+		sign := privateKeySigner(credential.Private, credential.Scope)
+		signer, isSigner := credential.Verifier.(Signer)
+		if isSigner {
+			sign = signer.Sign
+		}
+
+		if credential.Verifier != nil && !isSigner {
+			d.step("signature", true, "checked by verifier, not explained")
+		} else if e, explainErr := explain(r, sign); explainErr != nil {
+			d.step("body", false, explainErr.Error())
+		} else {
+			d.Explanation = e
+			switch {
+			case e.BodySize == 0 && e.ProvidedContentHash == "":
+				d.step("content hash", true, "no body")
+			case e.ProvidedContentHash == "":
+				d.step("content hash", false, fmt.Sprintf("missing X-Content-SHA256, expected %s", e.ContentHash))
+			default:
+				d.step("content hash", e.ContentHashMatches(), fmt.Sprintf("expected %s, got %s", e.ContentHash, e.ProvidedContentHash))
+			}
+			d.step("canonical request", true, e.CanonicalRequest)
+			d.step("string to sign", true, e.StringToSign)
+			d.step("signature", e.SignatureMatches(), fmt.Sprintf("expected %s, got %s", e.Signature, e.ProvidedSignature))
+		}
+	}
+
+	offline := &Authenticator{
+		public:        a.public,
+		private:       a.private,
+		verifier:      a.verifier,
+		timeTolerance: a.timeTolerance,
+		credentials:   a.credentials,
+		debug:         true,
+		clock:         func() time.Time { return at },
+		headerNames:   DefaultHeaderNames,
+		scope:         a.scope,
+		revocations:   a.revocations,
+	}
+	d.Result, d.Err = offline.Authenticate(r)
+
+	return d
+}
+
+// OK reports whether the request is valid.
+func (d *Diagnosis) OK() bool {
+	return d.Err == nil
+}
+
+// LogValue implements slog.LogValuer. The detail of the signature step,
+// which holds the expected and provided signatures, is never included.
+func (d *Diagnosis) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Time("at", d.At),
+		slog.Bool("ok", d.OK()),
+	}
+	for _, step := range d.Steps {
+		detail := step.Detail
+		if step.Name == "signature" {
+			detail = ""
+		}
+		attrs = append(attrs, slog.Group(step.Name, slog.Bool("ok", step.OK), slog.String("detail", detail)))
+	}
+	if d.Err != nil {
+		attrs = append(attrs, slog.Any("error", d.Err))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+func (d *Diagnosis) step(name string, ok bool, detail string) {
+	d.Steps = append(d.Steps, DiagnosisStep{Name: name, OK: ok, Detail: detail})
+}
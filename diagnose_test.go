@@ -0,0 +1,158 @@
+package hmac
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dumpTestRequest(t *testing.T, publicKey string, privateKey string) []byte {
+	t.Helper()
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	dump, err := httputil.DumpRequestOut(signedRequest, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dump
+}
+
+func diagnosisStep(t *testing.T, d *Diagnosis, name string) DiagnosisStep {
+	t.Helper()
+
+	for _, step := range d.Steps {
+		if step.Name == name {
+			return step
+		}
+	}
+	t.Fatalf("expected step %q in %+v", name, d.Steps)
+	return DiagnosisStep{}
+}
+
+func TestThatDiagnoseAcceptsRequestDumpAtRecordedTime(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	recorded, err := ReadRequestDump(bytes.NewReader(dumpTestRequest(t, publicKey, privateKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp, _ := strconv.ParseInt(recorded.Request.Header.Get("X-Timestamp"), 10, 64)
+	at := time.Unix(timestamp, 0)
+
+	clock := func() time.Time { return at.Add(time.Hour) }
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithClock(clock))
+
+	if _, err := authenticator.Authenticate(recorded.Request); err == nil {
+		t.Fatal("expected request to be outside the tolerance window")
+	}
+
+	diagnosis := authenticator.Diagnose(recorded.Request, at)
+	if !diagnosis.OK() {
+		t.Fatalf("expected valid request, got %v: %+v", diagnosis.Err, diagnosis.Steps)
+	}
+	for _, step := range diagnosis.Steps {
+		if !step.OK {
+			t.Fatalf("expected step %q to pass: %s", step.Name, step.Detail)
+		}
+	}
+}
+
+func TestThatDiagnoseReportsEveryFailingStep(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	dump := dumpTestRequest(t, publicKey, privateKey)
+	dump = bytes.Replace(dump, []byte(`{"foo": "bar"}`), []byte(`{"foo": "baz"}`), 1)
+	dump = bytes.Replace(dump, []byte("?abc=xyz"), []byte("?abc=xyZ"), 1)
+
+	recorded, err := ReadRequestDump(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	diagnosis := authenticator.Diagnose(recorded.Request, time.Now())
+
+	if diagnosis.OK() {
+		t.Fatal("expected tampered request to be invalid")
+	}
+	if diagnosisStep(t, diagnosis, "content hash").OK {
+		t.Fatal("expected content hash step to fail")
+	}
+	if diagnosisStep(t, diagnosis, "signature").OK {
+		t.Fatal("expected signature step to fail")
+	}
+	if !strings.Contains(diagnosisStep(t, diagnosis, "canonical request").Detail, "?abc=xyZ") {
+		t.Fatal("expected canonical request of the tampered query")
+	}
+}
+
+func TestThatReadHARParsesEntries(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	timestamp, _ := strconv.ParseInt(signedRequest.Header.Get("X-Timestamp"), 10, 64)
+
+	type header struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	var headers []header
+	for name := range signedRequest.Header {
+		headers = append(headers, header{name, signedRequest.Header.Get(name)})
+	}
+	entry := map[string]any{
+		"startedDateTime": time.Unix(timestamp, 0).UTC().Format(time.RFC3339),
+		"request": map[string]any{
+			"method":   signedRequest.Method,
+			"url":      signedRequest.URL.String(),
+			"headers":  headers,
+			"postData": map[string]string{"mimeType": "application/json", "text": `{"foo": "bar"}`},
+		},
+	}
+	har, _ := json.Marshal(map[string]any{"log": map[string]any{"entries": []any{entry}}})
+
+	recorded, err := ReadHAR(bytes.NewReader(har))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 1 || recorded[0].Time.Unix() != timestamp {
+		t.Fatalf("expected 1 entry recorded at %d, got %+v", timestamp, recorded)
+	}
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	diagnosis := authenticator.Diagnose(recorded[0].Request, recorded[0].Time)
+	if !diagnosis.OK() {
+		t.Fatalf("expected valid request, got %v: %+v", diagnosis.Err, diagnosis.Steps)
+	}
+}
+
+func TestThatDiagnosisLogValueOmitsSignatures(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+
+	request := signedTestRequest(t, publicKey, privateKey)
+	request.Header.Set("Signature", "forged")
+	diagnosis := authenticator.Diagnose(request, time.Now())
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("diagnosis", "diagnosis", diagnosis, "explanation", diagnosis.Explanation)
+
+	if strings.Contains(buf.String(), diagnosis.Explanation.Signature) || strings.Contains(buf.String(), "forged") {
+		t.Fatalf("expected no signatures in log, got %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"signature_matches":false`) {
+		t.Fatalf("expected signature outcome in log, got %s", buf.String())
+	}
+}
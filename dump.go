@@ -0,0 +1,111 @@
+package hmac
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RecordedRequest is a request captured outside the server, with the time
+// it was recorded, if known.
+type RecordedRequest struct {
+	Request *http.Request
+	Time    time.Time
+}
+
+// ReadRequestDump parses a raw HTTP/1.x request, such as the output of
+// httputil.DumpRequest or a capture from a proxy. The request is returned as
+// a server would receive it. Its recorded time is taken from the Date header,
+// if present.
+func ReadRequestDump(r io.Reader) (*RecordedRequest, error) {
+	request, err := http.ReadRequest(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("malformed request dump: %w", err)
+	}
+
+	content, err := io.ReadAll(request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read request body: %w", err)
+	}
+	request.Body = io.NopCloser(bytes.NewReader(content))
+
+	recorded := &RecordedRequest{Request: request}
+	if date := request.Header.Get("Date"); date != "" {
+		if t, err := http.ParseTime(date); err == nil {
+			recorded.Time = t
+		}
+	}
+
+	return recorded, nil
+}
+
+type harLog struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime time.Time `json:"startedDateTime"`
+			Request         struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// ReadHAR parses the requests of a HAR (HTTP Archive) export, recorded at
+// the start time of their entries.
+func ReadHAR(r io.Reader) ([]*RecordedRequest, error) {
+	var har harLog
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("malformed HAR: %w", err)
+	}
+
+	recorded := make([]*RecordedRequest, 0, len(har.Log.Entries))
+	for i, entry := range har.Log.Entries {
+		var content []byte
+		if postData := entry.Request.PostData; postData != nil {
+			content = []byte(postData.Text)
+			if postData.Encoding == "base64" {
+				decoded, err := base64.StdEncoding.DecodeString(postData.Text)
+				if err != nil {
+					return nil, fmt.Errorf("malformed HAR: entry %d: %w", i, err)
+				}
+				content = decoded
+			}
+		}
+
+		request, err := http.NewRequest(entry.Request.Method, entry.Request.URL, bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("malformed HAR: entry %d: %w", i, err)
+		}
+		request.RequestURI = request.URL.RequestURI()
+
+		for _, h := range entry.Request.Headers {
+			switch {
+			case strings.EqualFold(h.Name, "Host"), h.Name == ":authority":
+				request.Host = h.Value
+			case strings.HasPrefix(h.Name, ":"):
+				// Other HTTP/2 pseudo-headers are already part of the URL.
+			default:
+				request.Header.Add(h.Name, h.Value)
+			}
+		}
+
+		recorded = append(recorded, &RecordedRequest{Request: request, Time: entry.StartedDateTime})
+	}
+
+	return recorded, nil
+}
@@ -0,0 +1,93 @@
+package hmac
+
+import (
+	"expvar"
+	"sync"
+)
+
+// ExpvarObserver is an Observer that publishes counters with the expvar
+// package, under a single map named when it is created:
+//
+//	validations              validations by reason ("ok" for valid requests)
+//	credentials              validations by credential, then by reason
+//	signings                 signed requests by result ("ok" or "error")
+//	validation_seconds       total time spent validating
+//	nonce_store_seconds      total time spent in NonceStore calls
+//	signing_seconds          total time spent signing
+//	body_bytes               total size of validated request bodies
+type ExpvarObserver struct {
+	mu                sync.Mutex
+	validations       *expvar.Map
+	credentials       *expvar.Map
+	signings          *expvar.Map
+	validationSeconds *expvar.Float
+	nonceStoreSeconds *expvar.Float
+	signingSeconds    *expvar.Float
+	bodyBytes         *expvar.Int
+}
+
+// NewExpvarObserver creates an ExpvarObserver published under name. Like
+// expvar.Publish, it panics if name is already in use.
+func NewExpvarObserver(name string) *ExpvarObserver {
+	o := &ExpvarObserver{
+		validations:       new(expvar.Map).Init(),
+		credentials:       new(expvar.Map).Init(),
+		signings:          new(expvar.Map).Init(),
+		validationSeconds: new(expvar.Float),
+		nonceStoreSeconds: new(expvar.Float),
+		signingSeconds:    new(expvar.Float),
+		bodyBytes:         new(expvar.Int),
+	}
+
+	m := expvar.NewMap(name)
+	m.Set("validations", o.validations)
+	m.Set("credentials", o.credentials)
+	m.Set("signings", o.signings)
+	m.Set("validation_seconds", o.validationSeconds)
+	m.Set("nonce_store_seconds", o.nonceStoreSeconds)
+	m.Set("signing_seconds", o.signingSeconds)
+	m.Set("body_bytes", o.bodyBytes)
+
+	return o
+}
+
+func (o *ExpvarObserver) ObserveValidation(event ValidationEvent) {
+	reason := reasonLabel(event.Reason)
+
+	o.validations.Add(reason, 1)
+	if event.Credential != "" {
+		o.credential(event.Credential).Add(reason, 1)
+	}
+	o.validationSeconds.Add(event.Duration.Seconds())
+	o.nonceStoreSeconds.Add(event.NonceStoreDuration.Seconds())
+	o.bodyBytes.Add(int64(event.BodySize))
+}
+
+func (o *ExpvarObserver) ObserveSigning(event SigningEvent) {
+	result := "ok"
+	if event.Err != nil {
+		result = "error"
+	}
+
+	o.signings.Add(result, 1)
+	o.signingSeconds.Add(event.Duration.Seconds())
+}
+
+// credential returns the per-reason map of a credential, creating it if
+// needed.
+func (o *ExpvarObserver) credential(id string) *expvar.Map {
+	if m, ok := o.credentials.Get(id).(*expvar.Map); ok {
+		return m
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if m, ok := o.credentials.Get(id).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map).Init()
+	o.credentials.Set(id, m)
+
+	return m
+}
@@ -0,0 +1,120 @@
+package hmac
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// HeaderNames are the names of the headers that carry a signature. They only
+// change how the headers are sent: headers are always canonicalized under
+// their default names, so the signature does not depend on them. Clients and
+// servers must use the same names. Empty fields use the default name.
+type HeaderNames struct {
+	Authorization string
+	Credential    string
+	Signature     string
+	Timestamp     string
+	Nonce         string
+	ContentHash   string
+	SignedHeaders string
+	Scope         string
+}
+
+// DefaultHeaderNames are the header names used unless configured otherwise.
+var DefaultHeaderNames = HeaderNames{
+	Authorization: "Authorization",
+	Credential:    "Credential",
+	Signature:     "Signature",
+	Timestamp:     "X-Timestamp",
+	Nonce:         "X-Nonce",
+	ContentHash:   "X-Content-SHA256",
+	SignedHeaders: "X-Signed-Headers",
+	Scope:         "X-Scope",
+}
+
+// withDefaults returns n with empty fields set to their default names.
+func (n HeaderNames) withDefaults() HeaderNames {
+	defaults := DefaultHeaderNames.list()
+	names := n.list()
+	for i, name := range names {
+		if name == "" {
+			names[i] = defaults[i]
+		} else {
+			names[i] = http.CanonicalHeaderKey(name)
+		}
+	}
+
+	return HeaderNames{names[0], names[1], names[2], names[3], names[4], names[5], names[6], names[7]}
+}
+
+func (n HeaderNames) list() []string {
+	return []string{n.Authorization, n.Credential, n.Signature, n.Timestamp, n.Nonce, n.ContentHash, n.SignedHeaders, n.Scope}
+}
+
+// name returns the configured name of the header with the given default name.
+func (n HeaderNames) name(defaultName string) string {
+	for i, d := range DefaultHeaderNames.list() {
+		if d == defaultName {
+			return n.list()[i]
+		}
+	}
+
+	return defaultName
+}
+
+// toDefault returns header with the headers in n renamed to their default
+// names. header is returned as is when n are the default names.
+func (n HeaderNames) toDefault(header http.Header) http.Header {
+	if n == DefaultHeaderNames {
+		return header
+	}
+
+	renamed := header.Clone()
+	names := n.list()
+	for i, d := range DefaultHeaderNames.list() {
+		renamed.Del(d)
+		if values, ok := header[names[i]]; ok {
+			renamed[d] = values
+		}
+	}
+
+	return renamed
+}
+
+// signedHeaderNames returns the canonical names of the headers in names that
+// can be signed in addition to the signature headers, sorted and without
+// duplicates.
+func signedHeaderNames(names []string) []string {
+	reserved := make(map[string]bool)
+	for _, name := range DefaultHeaderNames.list() {
+		reserved[name] = true
+	}
+
+	var signed []string
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name == "" || reserved[name] {
+			continue
+		}
+		reserved[name] = true
+		signed = append(signed, name)
+	}
+	sort.Strings(signed)
+
+	return signed
+}
+
+// addSignedHeaders adds the values in header of the headers listed in its
+// X-Signed-Headers header, and that header itself, to headers.
+func addSignedHeaders(headers map[string]string, header http.Header) {
+	list := header.Get("X-Signed-Headers")
+	if list == "" {
+		return
+	}
+
+	headers["X-Signed-Headers"] = list
+	for _, name := range signedHeaderNames(strings.Split(list, ",")) {
+		headers[name] = strings.Join(header.Values(name), ",")
+	}
+}
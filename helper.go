@@ -15,6 +15,24 @@ func CreateCanonicalRequestString(method string, authority string, path string,
 		path = "/"
 	}
 
+	if len(query) != 0 {
+		query = "?" + query
+	}
+
+	return fmt.Sprintf("%s %s%s%s\n%s", method, authority, path, query, canonicalHeaders(headers))
+}
+
+// CreateCanonicalResponseString returns the canonical form of a response
+// with the given status code. requestNonce is the X-Nonce header of the
+// request being answered, which binds the response to that request. Its
+// first line, "RESPONSE", cannot start a canonical request, so that a
+// server signing responses with the key it validates requests with never
+// signs something that is also a valid request.
+func CreateCanonicalResponseString(status int, requestNonce string, headers map[string]string) string {
+	return fmt.Sprintf("RESPONSE\n%d %s\n%s", status, requestNonce, canonicalHeaders(headers))
+}
+
+func canonicalHeaders(headers map[string]string) string {
 	keys := make([]string, 0, len(headers))
 	for k := range headers {
 		keys = append(keys, k)
@@ -26,19 +44,22 @@ func CreateCanonicalRequestString(method string, authority string, path string,
 		headerString.WriteString(k + ":" + headers[k] + "\n")
 	}
 
-	if len(query) != 0 {
-		query = "?" + query
-	}
-
-	return fmt.Sprintf("%s %s%s%s\n%s", method, authority, path, query, headerString.String())
+	return headerString.String()
 }
 
-func CreateSignature(canonicalRequest string, timestamp int64, private string) string {
+// CreateStringToSign returns the string that CreateSignature signs: the
+// algorithm, the timestamp and the base64 SHA-256 hash of the canonical
+// request, separated by newlines.
+func CreateStringToSign(canonicalRequest string, timestamp int64) string {
 	requestHash := sha256.New()
 	requestHash.Write([]byte(canonicalRequest))
 	requestHashString := base64.StdEncoding.EncodeToString(requestHash.Sum(nil))
 
-	stringToSign := fmt.Sprintf("HMAC-SHA256\n%d\n%s", timestamp, requestHashString)
+	return fmt.Sprintf("HMAC-SHA256\n%d\n%s", timestamp, requestHashString)
+}
+
+func CreateSignature(canonicalRequest string, timestamp int64, private string) string {
+	stringToSign := CreateStringToSign(canonicalRequest, timestamp)
 
 	dateHash := hmac.New(sha256.New, []byte("HMAC"+private))
 	dateHash.Write([]byte(strconv.FormatInt(timestamp, 10)))
@@ -53,8 +74,11 @@ func CreateSignature(canonicalRequest string, timestamp int64, private string) s
 }
 
 func BuildHeaders(timestamp int64, content []byte) map[string]string {
+	return buildHeaders(timestamp, GenerateSecureRandom(8), content)
+}
+
+func buildHeaders(timestamp int64, nonce string, content []byte) map[string]string {
 	headers := make(map[string]string)
-	nonce := GenerateSecureRandom(8)
 
 	headers["X-Timestamp"] = strconv.FormatInt(timestamp, 10)
 	headers["X-Nonce"] = nonce
@@ -0,0 +1,233 @@
+// Package hmactest provides utilities for testing handlers protected by an
+// hmac.Authenticator and clients of such handlers.
+package hmactest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+// Clock is a fake clock for hmac.WithClock. It only moves when told to and
+// is safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Set sets the clock to now.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+}
+
+// Advance moves the clock forward by d, or backward if d is negative.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Nonces is a deterministic nonce source. It returns the 8-byte big-endian
+// hex encoding of a counter that starts at 1, so the first nonce is
+// "0000000000000001". It is safe for concurrent use.
+type Nonces struct {
+	mu   sync.Mutex
+	next uint64
+}
+
+// Next returns the next nonce.
+func (n *Nonces) Next() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.next++
+	return fmt.Sprintf("%016x", n.next)
+}
+
+// Signer builds signed requests for tests. Clock and Nonces are optional;
+// without them, requests are signed at the current time with random nonces.
+type Signer struct {
+	Public  string
+	Private string
+	Clock   *Clock
+	Nonces  *Nonces
+}
+
+// NewSigner returns a signer with a freshly generated key pair.
+func NewSigner() *Signer {
+	return &Signer{
+		Public:  hmac.GenerateSecureRandom(16),
+		Private: hmac.GenerateSecureRandom(16),
+	}
+}
+
+// Credential returns the key pair of s as a credential.
+func (s *Signer) Credential() *hmac.Credential {
+	credential, err := hmac.NewCredential(s.Public, s.Private)
+	if err != nil {
+		panic("hmactest: " + err.Error())
+	}
+
+	return credential
+}
+
+// Authenticator returns an authenticator for the key pair of s, using the
+// clock of s if it is set.
+func (s *Signer) Authenticator(timeTolerance int64, options ...hmac.AuthenticatorOption) *hmac.Authenticator {
+	if s.Clock != nil {
+		options = append([]hmac.AuthenticatorOption{hmac.WithClock(s.Clock.Now)}, options...)
+	}
+
+	authenticator, err := hmac.NewAuthenticator(s.Public, s.Private, timeTolerance, options...)
+	if err != nil {
+		panic("hmactest: " + err.Error())
+	}
+
+	return authenticator
+}
+
+// NewRequest returns a signed incoming server request, like
+// httptest.NewRequest, suitable for passing to an http.Handler. It panics
+// on error, which is acceptable in tests.
+func (s *Signer) NewRequest(method string, target string, body io.Reader) *http.Request {
+	request := httptest.NewRequest(method, target, body)
+	s.Sign(request)
+
+	return request
+}
+
+// Sign signs request in place and restores its body. It panics on error.
+func (s *Signer) Sign(request *http.Request) {
+	var options []hmac.RequestServiceOption
+	if s.Clock != nil {
+		options = append(options, hmac.WithSigningClock(s.Clock.Now))
+	}
+	if s.Nonces != nil {
+		options = append(options, hmac.WithSigningNonceSource(s.Nonces.Next))
+	}
+
+	requestService, err := hmac.NewRequestService(s.Public, s.Private, options...)
+	if err != nil {
+		panic("hmactest: " + err.Error())
+	}
+	if _, err := requestService.SignRequest(request); err != nil {
+		panic("hmactest: " + err.Error())
+	}
+}
+
+// Transport returns a RoundTripper that signs outgoing requests with s
+// before sending them with base, or http.DefaultTransport if base is nil.
+func (s *Signer) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		request = request.Clone(request.Context())
+		s.Sign(request)
+
+		return base.RoundTrip(request)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+// CredentialStore is an in-memory hmac.CredentialStore that is safe for
+// concurrent use.
+type CredentialStore struct {
+	mu          sync.RWMutex
+	credentials map[string]*hmac.Credential
+}
+
+// NewCredentialStore returns a store holding credentials.
+func NewCredentialStore(credentials ...*hmac.Credential) *CredentialStore {
+	store := &CredentialStore{credentials: make(map[string]*hmac.Credential)}
+	for _, credential := range credentials {
+		store.Add(credential)
+	}
+
+	return store
+}
+
+// Add adds credential to the store, replacing any credential with the same ID.
+func (s *CredentialStore) Add(credential *hmac.Credential) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.credentials[credential.ID] = credential
+}
+
+// Remove removes the credential with the given ID.
+func (s *CredentialStore) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.credentials, id)
+}
+
+// Credential implements hmac.CredentialStore.
+func (s *CredentialStore) Credential(id string) (*hmac.Credential, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	credential, ok := s.credentials[id]
+	return credential, ok
+}
+
+// NewServer starts and returns a new server that requires requests to be
+// validated by authenticator before passing them to handler. The caller
+// should call Close when finished, to shut it down.
+func NewServer(authenticator *hmac.Authenticator, handler http.Handler) *httptest.Server {
+	return httptest.NewServer(authenticator.Middleware(handler))
+}
+
+// AssertValid fails the test if err is not nil.
+func AssertValid(t testing.TB, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("expected valid request, got %v", err)
+	}
+}
+
+// AssertReason fails the test unless err is an *hmac.ValidationError with
+// the given reason.
+func AssertReason(t testing.TB, err error, reason hmac.Reason) {
+	t.Helper()
+
+	var validationErr *hmac.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected validation error with reason %q, got %v", reason, err)
+	}
+	if validationErr.Reason != reason {
+		t.Fatalf("expected reason %q, got %q: %v", reason, validationErr.Reason, err)
+	}
+}
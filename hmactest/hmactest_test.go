@@ -0,0 +1,96 @@
+package hmactest
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pascalallen/hmac/v2"
+)
+
+func TestThatSignedRequestIsValid(t *testing.T) {
+	signer := NewSigner()
+
+	request := signer.NewRequest(http.MethodPost, "http://localhost:8080/items?abc=xyz", strings.NewReader(`{"foo": "bar"}`))
+	_, err := signer.Authenticator(300).Authenticate(request)
+
+	AssertValid(t, err)
+}
+
+func TestThatSignerUsesClockAndNonces(t *testing.T) {
+	clock := NewClock(time.Unix(1700000000, 0))
+	signer := NewSigner()
+	signer.Clock = clock
+	signer.Nonces = &Nonces{}
+
+	first := signer.NewRequest(http.MethodGet, "/", nil)
+	second := signer.NewRequest(http.MethodGet, "/", nil)
+
+	if first.Header.Get("X-Timestamp") != "1700000000" {
+		t.Fatalf("expected timestamp 1700000000, got %q", first.Header.Get("X-Timestamp"))
+	}
+	if first.Header.Get("X-Nonce") != "0000000000000001" || second.Header.Get("X-Nonce") != "0000000000000002" {
+		t.Fatalf("unexpected nonces %q, %q", first.Header.Get("X-Nonce"), second.Header.Get("X-Nonce"))
+	}
+}
+
+func TestThatClockControlsTimestampValidation(t *testing.T) {
+	clock := NewClock(time.Unix(1700000000, 0))
+	signer := NewSigner()
+	signer.Clock = clock
+	authenticator := signer.Authenticator(300)
+
+	request := signer.NewRequest(http.MethodGet, "/", nil)
+	clock.Advance(301 * time.Second)
+	_, err := authenticator.Authenticate(request)
+
+	AssertReason(t, err, hmac.ReasonTimestampOutOfBounds)
+}
+
+func TestThatCredentialStoreAcceptsAddedCredentials(t *testing.T) {
+	owner, client := NewSigner(), NewSigner()
+	store := NewCredentialStore()
+	authenticator := owner.Authenticator(300, hmac.WithCredentialStore(store))
+
+	_, err := authenticator.Authenticate(client.NewRequest(http.MethodGet, "/", nil))
+	AssertReason(t, err, hmac.ReasonUnknownCredential)
+
+	store.Add(client.Credential())
+	_, err = authenticator.Authenticate(client.NewRequest(http.MethodGet, "/", nil))
+	AssertValid(t, err)
+
+	store.Remove(client.Public)
+	_, err = authenticator.Authenticate(client.NewRequest(http.MethodGet, "/", nil))
+	AssertReason(t, err, hmac.ReasonUnknownCredential)
+}
+
+func TestThatServerRequiresValidSignatures(t *testing.T) {
+	signer := NewSigner()
+	server := NewServer(signer.Authenticator(300), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422 for unsigned request, got %d", response.StatusCode)
+	}
+
+	client := &http.Client{Transport: signer.Transport(nil)}
+	response, err = client.Post(server.URL+"/items", "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK || string(body) != "payload" {
+		t.Fatalf("expected status 200 with echoed body, got %d: %s", response.StatusCode, body)
+	}
+}
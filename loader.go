@@ -0,0 +1,298 @@
+package hmac
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// CredentialSource loads a set of credentials, see CredentialsFromFile,
+// CredentialsFromEnv and CredentialsFromDir.
+type CredentialSource func() ([]*Credential, error)
+
+// credentialConfig is the JSON form of a Credential. Secret is the private
+// key, in any encoding accepted by ParseSecretKey.
+type credentialConfig struct {
+	ID        string            `json:"id"`
+	Secret    string            `json:"secret"`
+	Algorithm string            `json:"algorithm,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Debug     bool              `json:"debug,omitempty"`
+	Scope     string            `json:"scope,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at,omitzero"`
+	Revoked   bool              `json:"revoked,omitempty"`
+	Policy    *policyConfig     `json:"policy,omitempty"`
+	RateLimit *rateLimitConfig  `json:"rate_limit,omitempty"`
+}
+
+type policyConfig struct {
+	Methods   []string  `json:"methods,omitempty"`
+	Paths     []string  `json:"paths,omitempty"`
+	Hosts     []string  `json:"hosts,omitempty"`
+	Networks  []string  `json:"networks,omitempty"`
+	NotBefore time.Time `json:"not_before,omitzero"`
+	NotAfter  time.Time `json:"not_after,omitzero"`
+}
+
+type rateLimitConfig struct {
+	Requests int    `json:"requests"`
+	Period   string `json:"period"`
+	Burst    int    `json:"burst,omitempty"`
+}
+
+// credential validates c and returns the credential it describes.
+func (c credentialConfig) credential() (*Credential, error) {
+	if c.Algorithm != "" && c.Algorithm != "HMAC-SHA256" {
+		return nil, fmt.Errorf("credential %s: unsupported algorithm %q", c.ID, c.Algorithm)
+	}
+
+	credential, err := NewCredential(c.ID, c.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("credential %s: %w", c.ID, err)
+	}
+	credential.Metadata = c.Metadata
+	credential.Debug = c.Debug
+	credential.ExpiresAt = c.ExpiresAt
+	credential.Revoked = c.Revoked
+
+	if c.Scope != "" {
+		if _, err := ParseScope(c.Scope); err != nil {
+			return nil, fmt.Errorf("credential %s: %w", c.ID, err)
+		}
+		credential.Scope = c.Scope
+	}
+
+	if c.Policy != nil {
+		policy := &Policy{
+			Methods:   c.Policy.Methods,
+			Paths:     c.Policy.Paths,
+			Hosts:     c.Policy.Hosts,
+			NotBefore: c.Policy.NotBefore,
+			NotAfter:  c.Policy.NotAfter,
+		}
+		for _, network := range c.Policy.Networks {
+			prefix, err := netip.ParsePrefix(network)
+			if err != nil {
+				return nil, fmt.Errorf("credential %s: %w", c.ID, err)
+			}
+			policy.Networks = append(policy.Networks, prefix)
+		}
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("credential %s: %w", c.ID, err)
+		}
+		credential.Policy = policy
+	}
+
+	if c.RateLimit != nil {
+		period, err := time.ParseDuration(c.RateLimit.Period)
+		if err != nil || period <= 0 || c.RateLimit.Requests <= 0 {
+			return nil, fmt.Errorf("credential %s: invalid rate limit", c.ID)
+		}
+		credential.RateLimit = &RateLimit{Requests: c.RateLimit.Requests, Period: period, Burst: c.RateLimit.Burst}
+	}
+
+	return credential, nil
+}
+
+func parseCredentialConfigs(data []byte) ([]*Credential, error) {
+	var file struct {
+		Credentials []credentialConfig `json:"credentials"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("malformed credentials: %w", err)
+	}
+
+	credentials := make([]*Credential, 0, len(file.Credentials))
+	for _, c := range file.Credentials {
+		credential, err := c.credential()
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, nil
+}
+
+func parseCredentialConfig(data []byte) (*Credential, error) {
+	var c credentialConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("malformed credential: %w", err)
+	}
+
+	return c.credential()
+}
+
+// CredentialsFromFile loads credentials from a JSON file of the form
+//
+//	{"credentials": [{"id": "...", "secret": "<key>", "expires_at": "2027-01-01T00:00:00Z",
+//	  "policy": {"methods": ["GET"], "paths": ["/items/{id}"], "networks": ["10.0.0.0/8"]},
+//	  "rate_limit": {"requests": 100, "period": "1m"}}]}
+//
+// Every field but id and secret is optional. secret is parsed with
+// ParseSecretKey. algorithm, if set, must be HMAC-SHA256.
+func CredentialsFromFile(path string) CredentialSource {
+	return func() ([]*Credential, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return parseCredentialConfigs(data)
+	}
+}
+
+// CredentialsFromEnv loads a credential from every environment variable
+// whose name starts with prefix. The value is either "<id>:<secret>" or
+// a JSON credential as in CredentialsFromFile.
+func CredentialsFromEnv(prefix string) CredentialSource {
+	return func() ([]*Credential, error) {
+		var credentials []*Credential
+		for _, variable := range os.Environ() {
+			name, value, _ := strings.Cut(variable, "=")
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+
+			var credential *Credential
+			var err error
+			if strings.HasPrefix(strings.TrimSpace(value), "{") {
+				credential, err = parseCredentialConfig([]byte(value))
+			} else {
+				id, secret, _ := strings.Cut(value, ":")
+				credential, err = credentialConfig{ID: id, Secret: secret}.credential()
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			credentials = append(credentials, credential)
+		}
+
+		return credentials, nil
+	}
+}
+
+// CredentialsFromDir loads a credential from every file in dir, such as a
+// mounted Kubernetes secret. A file whose name ends in .json holds a JSON
+// credential as in CredentialsFromFile; any other file is named after the
+// credential ID and holds its secret. Files whose name starts with a dot
+// are ignored.
+func CredentialsFromDir(dir string) CredentialSource {
+	return func() ([]*Credential, error) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		var credentials []*Credential
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.Mode().IsRegular() {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+
+			var credential *Credential
+			if id, ok := strings.CutSuffix(name, ".json"); ok {
+				credential, err = parseCredentialConfig(data)
+				if err == nil && credential.ID != id {
+					err = fmt.Errorf("credential %s does not match file name", credential.ID)
+				}
+			} else {
+				credential, err = credentialConfig{ID: name, Secret: strings.TrimSpace(string(data))}.credential()
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			credentials = append(credentials, credential)
+		}
+
+		return credentials, nil
+	}
+}
+
+// CredentialLoader is a CredentialStore holding the credentials of a set of
+// sources. Reload replaces them atomically, so requests validated during a
+// reload see either the old or the new credentials.
+type CredentialLoader struct {
+	sources     []CredentialSource
+	credentials atomic.Pointer[map[string]*Credential]
+}
+
+// NewCredentialLoader loads the credentials of sources. It fails if any
+// source fails or two sources define the same credential.
+func NewCredentialLoader(sources ...CredentialSource) (*CredentialLoader, error) {
+	l := &CredentialLoader{sources: sources}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Credential implements CredentialStore.
+func (l *CredentialLoader) Credential(id string) (*Credential, bool) {
+	credential, ok := (*l.credentials.Load())[id]
+	return credential, ok
+}
+
+// Reload loads the credentials of every source again and swaps them in. On
+// error, the current credentials are kept. Replaced credentials are not
+// zeroed, since requests being validated may still read them, and are left
+// to the garbage collector.
+func (l *CredentialLoader) Reload() error {
+	credentials := make(map[string]*Credential)
+	for _, source := range l.sources {
+		loaded, err := source()
+		if err != nil {
+			return err
+		}
+		for _, credential := range loaded {
+			if _, ok := credentials[credential.ID]; ok {
+				return fmt.Errorf("duplicate credential %s", credential.ID)
+			}
+			credentials[credential.ID] = credential
+		}
+	}
+
+	l.credentials.Store(&credentials)
+
+	return nil
+}
+
+// Watch calls Reload every interval until ctx is done, so that changes to
+// the sources take effect without a restart. Reload errors are passed to
+// onError, if not nil, and the current credentials are kept.
+func (l *CredentialLoader) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
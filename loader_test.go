@@ -0,0 +1,177 @@
+package hmac
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestThatCredentialsFromFileLoadsCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	os.WriteFile(path, []byte(`{"credentials": [{
+		"id": "client-1",
+		"secret": "00112233445566778899aabbccddeeff",
+		"algorithm": "HMAC-SHA256",
+		"metadata": {"tenant": "acme"},
+		"expires_at": "2027-01-01T00:00:00Z",
+		"policy": {"methods": ["GET"], "paths": ["/items/{id}"], "networks": ["10.0.0.0/8"]},
+		"rate_limit": {"requests": 100, "period": "1m"}
+	}]}`), 0600)
+
+	credentials, err := CredentialsFromFile(path)()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential := credentials[0]
+	if credential.ID != "client-1" || len(credential.Private) != 16 || credential.Metadata["tenant"] != "acme" {
+		t.Fatalf("unexpected credential %+v", credential)
+	}
+	if credential.ExpiresAt.Year() != 2027 || credential.Policy.Networks[0].String() != "10.0.0.0/8" {
+		t.Fatalf("unexpected expiry %s or policy %+v", credential.ExpiresAt, credential.Policy)
+	}
+	if credential.RateLimit.Requests != 100 || credential.RateLimit.Period != time.Minute {
+		t.Fatalf("unexpected rate limit %+v", credential.RateLimit)
+	}
+}
+
+func TestThatCredentialsFromFileRejectsInvalidCredentials(t *testing.T) {
+	for _, config := range []string{
+		`{"credentials": [{"id": "client-1", "secret": "not hex"}]}`,
+		`{"credentials": [{"id": "", "secret": "0011"}]}`,
+		`{"credentials": [{"id": "client-1", "secret": "0011", "algorithm": "HMAC-SHA1"}]}`,
+		`{"credentials": [{"id": "client-1", "secret": "0011", "policy": {"paths": ["/items/{"]}}]}`,
+		`{"credentials": [{"id": "client-1", "secret": "0011", "policy": {"networks": ["10.0.0.0"]}}]}`,
+		`{"credentials": [{"id": "client-1", "secret": "0011", "rate_limit": {"requests": 1, "period": "soon"}}]}`,
+		`{"credentials": [{"id": "client-1", "secret": "0011", "scope": "today"}]}`,
+	} {
+		path := filepath.Join(t.TempDir(), "credentials.json")
+		os.WriteFile(path, []byte(config), 0600)
+
+		if _, err := CredentialsFromFile(path)(); err == nil {
+			t.Errorf("expected error for %s", config)
+		}
+	}
+}
+
+func TestThatCredentialsFromEnvLoadsCredentials(t *testing.T) {
+	t.Setenv("TEST_HMAC_CREDENTIAL_A", "client-a:00112233445566778899aabbccddeeff")
+	t.Setenv("TEST_HMAC_CREDENTIAL_B", `{"id": "client-b", "secret": "ffeeddccbbaa99887766554433221100", "debug": true}`)
+
+	credentials, err := CredentialsFromEnv("TEST_HMAC_CREDENTIAL_")()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := map[string]bool{}
+	for _, credential := range credentials {
+		ids[credential.ID] = true
+	}
+	if len(credentials) != 2 || !ids["client-a"] || !ids["client-b"] {
+		t.Fatalf("unexpected credentials %v", credentials)
+	}
+}
+
+func TestThatCredentialsFromDirLoadsSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "client-a"), []byte("00112233445566778899aabbccddeeff\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "client-b.json"), []byte(`{"id": "client-b", "secret": "ffeeddccbbaa99887766554433221100"}`), 0600)
+	os.WriteFile(filepath.Join(dir, ".hidden"), []byte("not a credential"), 0600)
+	os.Mkdir(filepath.Join(dir, "..data"), 0700)
+
+	credentials, err := CredentialsFromDir(dir)()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(credentials) != 2 || credentials[0].ID != "client-a" || credentials[1].ID != "client-b" {
+		t.Fatalf("unexpected credentials %v", credentials)
+	}
+}
+
+func TestThatCredentialLoaderRejectsDuplicateCredentials(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "client-a"), []byte("00112233445566778899aabbccddeeff"), 0600)
+
+	if _, err := NewCredentialLoader(CredentialsFromDir(dir), CredentialsFromDir(dir)); err == nil {
+		t.Fatal("expected error for duplicate credential")
+	}
+}
+
+func TestThatCredentialLoaderReloadsCredentials(t *testing.T) {
+	dir := t.TempDir()
+	clientPublicKey := GenerateSecureRandom(16)
+	clientPrivateKey := GenerateSecureRandom(16)
+	loader, err := NewCredentialLoader(CredentialsFromDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300, WithCredentialStore(loader))
+
+	_, err = authenticator.Authenticate(signedTestRequest(t, clientPublicKey, clientPrivateKey))
+	assertValidationError(t, err, "Not authorized")
+
+	os.WriteFile(filepath.Join(dir, clientPublicKey), []byte(clientPrivateKey), 0600)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go loader.Watch(ctx, time.Millisecond, nil)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := loader.Credential(clientPublicKey); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected credential to be loaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := authenticator.Authenticate(signedTestRequest(t, clientPublicKey, clientPrivateKey)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThatCredentialLoaderKeepsCredentialsOnReloadError(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "client-a"), []byte("00112233445566778899aabbccddeeff"), 0600)
+	loader, _ := NewCredentialLoader(CredentialsFromDir(dir))
+
+	os.WriteFile(filepath.Join(dir, "client-b"), []byte("not hex"), 0600)
+
+	if err := loader.Reload(); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if _, ok := loader.Credential("client-a"); !ok {
+		t.Fatal("expected previous credentials to be kept")
+	}
+}
+
+func TestThatCredentialLoaderLeavesReplacedCredentialsIntact(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "rotated"), []byte(GenerateSecureRandom(16)), 0600)
+	os.WriteFile(filepath.Join(dir, "removed"), []byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 16))), 0600)
+	loader, err := NewCredentialLoader(CredentialsFromDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, _ := loader.Credential("rotated")
+	removed, _ := loader.Credential("removed")
+	rotatedKey, removedKey := bytes.Clone(rotated.Private), bytes.Clone(removed.Private)
+
+	os.WriteFile(filepath.Join(dir, "rotated"), []byte(GenerateSecureRandom(16)), 0600)
+	os.Remove(filepath.Join(dir, "removed"))
+	if err := loader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(rotated.Private, rotatedKey) || !bytes.Equal(removed.Private, removedKey) {
+		t.Fatal("expected credentials held by in-flight requests to be left intact")
+	}
+	if _, ok := loader.Credential("removed"); ok {
+		t.Fatal("expected removed credential to be gone")
+	}
+}
@@ -0,0 +1,330 @@
+package hmac
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LockoutAlert describes a credential or source address that was locked out
+// after repeated signature failures. Exactly one of Credential and Address
+// is set. Lockouts counts the consecutive lockouts, so that alerting can
+// escalate when a credential keeps being attacked.
+type LockoutAlert struct {
+	Credential string
+	Address    string
+	Failures   int
+	Lockouts   int
+	Until      time.Time
+}
+
+// LockoutStatus is the lockout state of a credential or source address.
+type LockoutStatus struct {
+	Credential string
+	Address    string
+	Failures   int
+	Lockouts   int
+	Until      time.Time
+}
+
+type lockoutEntry struct {
+	failures    []time.Time
+	lockouts    int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+type lockoutKey struct {
+	credential string
+	address    string
+}
+
+// Lockout tracks signature failures per credential and per source address
+// and locks out those that fail too often, for a duration that doubles with
+// every consecutive lockout. Locking out a credential also locks out its
+// legitimate client, so thresholds should be well above the failures a
+// misconfigured client produces. Lockouts only apply to the process the
+// Lockout runs in.
+type Lockout struct {
+	mu        sync.Mutex
+	entries   map[lockoutKey]*lockoutEntry
+	threshold int
+	window    time.Duration
+	base      time.Duration
+	max       time.Duration
+	alert     func(LockoutAlert)
+	address   func(*http.Request) string
+	pruned    time.Time
+	now       func() time.Time
+}
+
+// maxLockoutEntries bounds the number of entries tracked by a Lockout. Once
+// it is reached, failures are only counted for credentials and addresses
+// that already have an entry.
+const maxLockoutEntries = 100000
+
+type LockoutOption func(*Lockout)
+
+// WithLockoutThreshold locks out after failures signature failures within
+// window. The default is 10 failures within 5 minutes.
+func WithLockoutThreshold(failures int, window time.Duration) LockoutOption {
+	return func(l *Lockout) {
+		l.threshold = failures
+		l.window = window
+	}
+}
+
+// WithLockoutBackoff sets the duration of the first lockout and the maximum
+// duration of a lockout. The default is 1 minute, up to 1 hour. The
+// lockout count resets after max without failures.
+func WithLockoutBackoff(base time.Duration, max time.Duration) LockoutOption {
+	return func(l *Lockout) {
+		l.base = base
+		l.max = max
+	}
+}
+
+// WithLockoutAlert calls alert whenever a credential or source address is
+// locked out. alert is called synchronously and must not block.
+func WithLockoutAlert(alert func(LockoutAlert)) LockoutOption {
+	return func(l *Lockout) {
+		l.alert = alert
+	}
+}
+
+// WithLockoutClientAddress makes a Lockout count failures for the address
+// returned by address instead of the host of the request's RemoteAddr.
+// Behind a load balancer or reverse proxy, RemoteAddr is the address of the
+// proxy, shared by every client, so that a few invalid signatures lock
+// everyone out: return the client address the proxy reports, such as the
+// last entry of X-Forwarded-For it appended, and only trust headers the
+// proxy sets. Returning an empty string disables per-address lockout for the
+// request.
+func WithLockoutClientAddress(address func(r *http.Request) string) LockoutOption {
+	return func(l *Lockout) {
+		l.address = address
+	}
+}
+
+// NewLockout returns a Lockout for use with WithLockout.
+func NewLockout(options ...LockoutOption) *Lockout {
+	l := &Lockout{
+		entries:   make(map[lockoutKey]*lockoutEntry),
+		threshold: 10,
+		window:    5 * time.Minute,
+		base:      time.Minute,
+		max:       time.Hour,
+		address:   remoteHost,
+		now:       time.Now,
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	return l
+}
+
+// WithLockout rejects requests of locked out credentials and source
+// addresses with status 429 and ReasonLockedOut, before validating them.
+// Invalid signatures count as failures of both the credential and the
+// address, unknown credentials as failures of the address.
+func WithLockout(lockout *Lockout) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.lockout = lockout
+	}
+}
+
+// Unlock lifts the lockout of credential and forgets its failures.
+func (l *Lockout) Unlock(credential string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, lockoutKey{credential: credential})
+}
+
+// UnlockAddress lifts the lockout of a source IP address, or of the /64
+// prefix of an IPv6 address, and forgets its failures.
+func (l *Lockout) UnlockAddress(address string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, lockoutKey{address: lockoutAddress(address)})
+}
+
+// Locked returns the credentials and source addresses that are currently
+// locked out, ordered by the end of their lockout.
+func (l *Lockout) Locked() []LockoutStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var locked []LockoutStatus
+	for key, entry := range l.entries {
+		if entry.lockedUntil.After(now) {
+			locked = append(locked, LockoutStatus{
+				Credential: key.credential,
+				Address:    key.address,
+				Failures:   len(entry.failures),
+				Lockouts:   entry.lockouts,
+				Until:      entry.lockedUntil,
+			})
+		}
+	}
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].Until.Before(locked[j].Until)
+	})
+
+	return locked
+}
+
+// check returns an error if credential or the address of r is locked out.
+func (l *Lockout) check(r *http.Request, credential string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for _, key := range l.keys(r, credential) {
+		if entry, ok := l.entries[key]; ok && entry.lockedUntil.After(now) {
+			return &ValidationError{
+				Code:    http.StatusTooManyRequests,
+				Reason:  ReasonLockedOut,
+				Message: "Too many failed attempts",
+			}
+		}
+	}
+
+	return nil
+}
+
+// fail records a failed validation of a request of credential.
+func (l *Lockout) fail(r *http.Request, credential string, reason Reason) {
+	var keys []lockoutKey
+	switch reason {
+	case ReasonInvalidSignature:
+		keys = l.keys(r, credential)
+	case ReasonUnknownCredential:
+		keys = l.keys(r, "")
+	default:
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for _, key := range keys {
+		entry, ok := l.entries[key]
+		if !ok {
+			// A store such as KeyDeriver accepts any credential ID, and
+			// a client may rotate through many source addresses, so
+			// entries are only added while there is room.
+			if len(l.entries) >= maxLockoutEntries {
+				l.pruned = time.Time{}
+				l.prune(now)
+				if len(l.entries) >= maxLockoutEntries {
+					continue
+				}
+			}
+			entry = &lockoutEntry{}
+			l.entries[key] = entry
+		}
+		if now.Sub(entry.lastFailure) > l.max {
+			entry.lockouts = 0
+		}
+		entry.lastFailure = now
+		entry.failures = append(recent(entry.failures, now.Add(-l.window)), now)
+
+		if len(entry.failures) < l.threshold {
+			continue
+		}
+		entry.lockouts++
+		entry.lockedUntil = now.Add(l.backoff(entry.lockouts))
+		alert := LockoutAlert{
+			Credential: key.credential,
+			Address:    key.address,
+			Failures:   len(entry.failures),
+			Lockouts:   entry.lockouts,
+			Until:      entry.lockedUntil,
+		}
+		entry.failures = nil
+		if l.alert != nil {
+			l.alert(alert)
+		}
+	}
+
+	l.prune(now)
+}
+
+func (l *Lockout) backoff(lockouts int) time.Duration {
+	d := l.base
+	for i := 1; i < lockouts && d < l.max; i++ {
+		d *= 2
+	}
+
+	return min(d, l.max)
+}
+
+// prune drops the entries without recent failures or lockout, at most once
+// per window.
+func (l *Lockout) prune(now time.Time) {
+	if now.Sub(l.pruned) < l.window {
+		return
+	}
+	l.pruned = now
+
+	for key, entry := range l.entries {
+		if now.Sub(entry.lastFailure) > l.max && !entry.lockedUntil.After(now) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// recent returns the times in failures after since.
+func recent(failures []time.Time, since time.Time) []time.Time {
+	i := sort.Search(len(failures), func(i int) bool {
+		return failures[i].After(since)
+	})
+
+	return failures[i:]
+}
+
+// keys returns the keys of credential, if not empty, and of the source
+// address of r, if known.
+func (l *Lockout) keys(r *http.Request, credential string) []lockoutKey {
+	var keys []lockoutKey
+	if credential != "" {
+		keys = append(keys, lockoutKey{credential: credential})
+	}
+	if address := l.address(r); address != "" {
+		keys = append(keys, lockoutKey{address: lockoutAddress(address)})
+	}
+
+	return keys
+}
+
+// remoteHost returns the host of the RemoteAddr of r, or an empty string.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+
+	return host
+}
+
+// lockoutAddress returns the key of a source address: the /64 prefix of an
+// IPv6 address, since a single client usually controls a whole /64, and the
+// address itself otherwise.
+func lockoutAddress(address string) string {
+	ip, err := netip.ParseAddr(address)
+	if err != nil || !ip.Is6() || ip.Is4In6() {
+		return address
+	}
+
+	prefix, _ := ip.Prefix(64)
+	return prefix.String()
+}
@@ -0,0 +1,174 @@
+package hmac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestThatLockoutLocksCredentialAfterRepeatedFailures(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	var alerts []LockoutAlert
+	lockout := NewLockout(WithLockoutThreshold(3, time.Minute), WithLockoutAlert(func(alert LockoutAlert) {
+		alerts = append(alerts, alert)
+	}))
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithLockout(lockout))
+
+	for i := 0; i < 3; i++ {
+		_, err := authenticator.Authenticate(signedTestRequest(t, publicKey, GenerateSecureRandom(16)))
+		assertValidationError(t, err, "Not authorized")
+	}
+
+	_, err := authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey))
+	assertValidationError(t, err, "Too many failed attempts")
+	if len(alerts) != 1 || alerts[0].Credential != publicKey || alerts[0].Failures != 3 {
+		t.Fatalf("expected a single alert for the credential, got %+v", alerts)
+	}
+	if locked := lockout.Locked(); len(locked) != 1 || locked[0].Credential != publicKey {
+		t.Fatalf("expected credential to be locked, got %+v", locked)
+	}
+
+	lockout.Unlock(publicKey)
+	if _, err := authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThatLockoutLocksSourceAddress(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	lockout := NewLockout(WithLockoutThreshold(2, time.Minute))
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithLockout(lockout))
+
+	for i := 0; i < 2; i++ {
+		request := signedTestRequest(t, GenerateSecureRandom(16), privateKey)
+		request.RemoteAddr = "192.0.2.1:1234"
+		authenticator.Authenticate(request)
+	}
+
+	request := signedTestRequest(t, publicKey, privateKey)
+	request.RemoteAddr = "192.0.2.1:4321"
+	_, err := authenticator.Authenticate(request)
+	assertValidationError(t, err, "Too many failed attempts")
+
+	request = signedTestRequest(t, publicKey, privateKey)
+	request.RemoteAddr = "192.0.2.2:1234"
+	if _, err := authenticator.Authenticate(request); err != nil {
+		t.Fatal(err)
+	}
+
+	lockout.UnlockAddress("192.0.2.1")
+	request = signedTestRequest(t, publicKey, privateKey)
+	request.RemoteAddr = "192.0.2.1:4321"
+	if _, err := authenticator.Authenticate(request); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThatLockoutBacksOffExponentially(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	lockout := NewLockout(WithLockoutThreshold(1, time.Minute), WithLockoutBackoff(time.Minute, 3*time.Minute))
+	lockout.now = func() time.Time { return now }
+	request := signedTestRequest(t, GenerateSecureRandom(16), GenerateSecureRandom(16))
+
+	for _, expected := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		lockout.fail(request, "client", ReasonInvalidSignature)
+		locked := lockout.Locked()
+		if len(locked) != 1 || locked[0].Until.Sub(now) != expected {
+			t.Fatalf("expected lockout of %s, got %+v", expected, locked)
+		}
+		now = locked[0].Until
+	}
+
+	now = now.Add(4 * time.Minute)
+	lockout.fail(request, "client", ReasonInvalidSignature)
+	if locked := lockout.Locked(); len(locked) != 1 || locked[0].Lockouts != 1 {
+		t.Fatalf("expected lockout count to reset, got %+v", locked)
+	}
+}
+
+func TestThatLockoutBoundsEntries(t *testing.T) {
+	lockout := NewLockout()
+	until := time.Now().Add(time.Hour)
+	for i := range maxLockoutEntries - 1 {
+		lockout.entries[lockoutKey{address: strconv.Itoa(i)}] = &lockoutEntry{lockedUntil: until, lastFailure: time.Now()}
+	}
+	lockout.entries[lockoutKey{address: "192.0.2.1"}] = &lockoutEntry{lastFailure: time.Now()}
+
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+	lockout.fail(request, "invented", ReasonInvalidSignature)
+	request.RemoteAddr = "[2001:db8::1]:1234"
+	lockout.fail(request, "", ReasonUnknownCredential)
+
+	if len(lockout.entries) != maxLockoutEntries {
+		t.Fatalf("expected %d entries, got %d", maxLockoutEntries, len(lockout.entries))
+	}
+	if entry := lockout.entries[lockoutKey{address: "192.0.2.1"}]; len(entry.failures) != 1 {
+		t.Fatal("expected failure to be counted for the known source address")
+	}
+}
+
+func TestThatLockoutGroupsIPv6AddressesByPrefix(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	lockout := NewLockout(WithLockoutThreshold(2, time.Minute))
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithLockout(lockout))
+
+	for _, address := range []string{"[2001:db8::1]:1234", "[2001:db8::2]:1234"} {
+		request := signedTestRequest(t, GenerateSecureRandom(16), privateKey)
+		request.RemoteAddr = address
+		authenticator.Authenticate(request)
+	}
+
+	request := signedTestRequest(t, publicKey, privateKey)
+	request.RemoteAddr = "[2001:db8::ffff]:1234"
+	_, err := authenticator.Authenticate(request)
+	assertValidationError(t, err, "Too many failed attempts")
+
+	lockout.UnlockAddress("2001:db8::3")
+	if _, err := authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey)); err != nil {
+		t.Fatal(err)
+	}
+	if locked := lockout.Locked(); len(locked) != 0 {
+		t.Fatalf("expected prefix to be unlocked, got %+v", locked)
+	}
+}
+
+func TestThatLockoutUsesClientAddressFunction(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	lockout := NewLockout(WithLockoutThreshold(2, time.Minute), WithLockoutClientAddress(func(r *http.Request) string {
+		return r.Header.Get("X-Real-IP")
+	}))
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithLockout(lockout))
+
+	for i := 0; i < 2; i++ {
+		request := signedTestRequest(t, GenerateSecureRandom(16), privateKey)
+		request.RemoteAddr = "10.0.0.1:1234"
+		request.Header.Set("X-Real-IP", "192.0.2.1")
+		authenticator.Authenticate(request)
+	}
+
+	request := signedTestRequest(t, publicKey, privateKey)
+	request.RemoteAddr = "10.0.0.1:1234"
+	request.Header.Set("X-Real-IP", "192.0.2.2")
+	if _, err := authenticator.Authenticate(request); err != nil {
+		t.Fatalf("expected other clients behind the proxy to be accepted, got %v", err)
+	}
+	if locked := lockout.Locked(); len(locked) != 1 || locked[0].Address != "192.0.2.1" {
+		t.Fatalf("expected client address to be locked, got %+v", locked)
+	}
+}
+
+func TestThatLockoutClientAddressCanDisableAddressLockout(t *testing.T) {
+	lockout := NewLockout(WithLockoutThreshold(1, time.Minute), WithLockoutClientAddress(func(*http.Request) string { return "" }))
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+
+	lockout.fail(request, "", ReasonUnknownCredential)
+	if len(lockout.entries) != 0 {
+		t.Fatalf("expected no address entries, got %d", len(lockout.entries))
+	}
+}
@@ -0,0 +1,33 @@
+package hmac
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Middleware rejects requests that fail validation, including those over
+// their rate limit, and passes the rest to next with the Result stored in the
+// request context. See FromContext and WithRateLimiter.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := a.Authenticate(r)
+		if err != nil {
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			writeRateLimitHeaders(w, validationErr.rateLimit)
+			message := validationErr.Message
+			if validationErr.CanonicalRequest != "" {
+				message += "\n\nCanonical request:\n" + validationErr.CanonicalRequest +
+					"\nString to sign:\n" + validationErr.StringToSign
+			}
+			http.Error(w, message, validationErr.Code)
+			return
+		}
+
+		writeRateLimitHeaders(w, result.rateLimitStatus)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), result)))
+	})
+}
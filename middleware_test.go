@@ -0,0 +1,62 @@
+package hmac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestThatMiddlewareStoresResultInContext(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+
+	var result *Result
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, _ = FromContext(r.Context())
+	})
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	recorder := httptest.NewRecorder()
+	authenticator.Middleware(handler).ServeHTTP(recorder, signedRequest)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if result == nil || result.Credential != publicKey {
+		t.Fatalf("expected result for credential %q in context, got %+v", publicKey, result)
+	}
+}
+
+func TestThatMiddlewareRejectsInvalidRequest(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	signedRequest.Header.Set("Signature", "invalid signature")
+
+	called := false
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	recorder := httptest.NewRecorder()
+	authenticator.Middleware(handler).ServeHTTP(recorder, signedRequest)
+
+	if called {
+		t.Fatal("expected handler not to be called")
+	}
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, recorder.Code)
+	}
+}
+
+func TestThatFromContextReturnsFalseWithoutResult(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+
+	if _, ok := FromContext(request.Context()); ok {
+		t.Fatal("expected no result in context")
+	}
+}
@@ -0,0 +1,70 @@
+package hmac
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryNonceStore is an in-memory NonceStore that remembers nonces for a
+// fixed time. It only protects the process it runs in; use shared storage
+// to reject replays across servers. It is safe for concurrent use.
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	ttl    time.Duration
+	pruned time.Time
+	now    func() time.Time
+}
+
+// NewMemoryNonceStore returns a store that remembers nonces for ttl. Since
+// request timestamps may be off by the time tolerance in either direction,
+// ttl should be at least twice the time tolerance of the Authenticator.
+func NewMemoryNonceStore(ttl time.Duration) *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces: make(map[string]time.Time),
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// Seen implements NonceStore. It also records nonce, so that of concurrent
+// requests with the same nonce only the first is accepted.
+func (s *MemoryNonceStore) Seen(nonce string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if expires, ok := s.nonces[nonce]; ok && now.Before(expires) {
+		return true
+	}
+	s.store(nonce, now)
+
+	return false
+}
+
+// Store implements NonceStore.
+func (s *MemoryNonceStore) Store(nonce string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.store(nonce, s.now())
+}
+
+func (s *MemoryNonceStore) store(nonce string, now time.Time) {
+	s.nonces[nonce] = now.Add(s.ttl)
+	s.prune(now)
+}
+
+// prune drops expired nonces, at most once per ttl.
+func (s *MemoryNonceStore) prune(now time.Time) {
+	if now.Sub(s.pruned) < s.ttl {
+		return
+	}
+	s.pruned = now
+
+	for nonce, expires := range s.nonces {
+		if !now.Before(expires) {
+			delete(s.nonces, nonce)
+		}
+	}
+}
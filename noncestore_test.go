@@ -0,0 +1,42 @@
+package hmac
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThatMemoryNonceStoreForgetsNoncesAfterTTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryNonceStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	if store.Seen("a") {
+		t.Fatal("expected new nonce not to be seen")
+	}
+	store.Store("a")
+	if !store.Seen("a") {
+		t.Fatal("expected stored nonce to be seen")
+	}
+
+	now = now.Add(time.Minute)
+	if store.Seen("a") {
+		t.Fatal("expected nonce to expire after the TTL")
+	}
+	store.Seen("b")
+	if len(store.nonces) != 2 || !store.Seen("a") {
+		t.Fatalf("expected expired nonce to be recorded again, got %v", store.nonces)
+	}
+}
+
+func TestThatAuthenticatorRejectsReplayWithMemoryNonceStore(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithNonceStore(NewMemoryNonceStore(600*time.Second)))
+	request := signedTestRequest(t, publicKey, privateKey)
+
+	if _, err := authenticator.Authenticate(request); err != nil {
+		t.Fatal(err)
+	}
+	_, err := authenticator.Authenticate(request)
+	assertValidationError(t, err, "Nonce already used")
+}
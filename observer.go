@@ -0,0 +1,70 @@
+package hmac
+
+import "time"
+
+// Observer is notified of every validation performed by an Authenticator
+// and every request signed by a RequestService. Implementations must be safe
+// for concurrent use and should return quickly, since they are called on the
+// request path.
+type Observer interface {
+	ObserveValidation(event ValidationEvent)
+	ObserveSigning(event SigningEvent)
+}
+
+// ValidationEvent describes a single call to Authenticator.Validate or
+// Authenticator.Authenticate.
+type ValidationEvent struct {
+	// Reason is the failure reason, or empty when the request is valid.
+	Reason Reason
+	// Credential is the ID of the credential that signed the request. It is
+	// empty unless the signature is valid, so that clients cannot create
+	// arbitrary metric labels.
+	Credential string
+	// Duration is the total time spent validating the request.
+	Duration time.Duration
+	// NonceStoreDuration is the time spent in NonceStore calls.
+	NonceStoreDuration time.Duration
+	// BodySize is the length of the request body in bytes, if it was read.
+	BodySize int
+	// Skew is the request timestamp minus the server time, when the
+	// timestamp could be parsed.
+	Skew time.Duration
+}
+
+// SigningEvent describes a single call to RequestService.SignRequest.
+type SigningEvent struct {
+	Credential string
+	Duration   time.Duration
+	BodySize   int
+	// Err is the error returned by SignRequest, if any.
+	Err error
+}
+
+// Observers returns an Observer that notifies each of observers in turn, for
+// example metrics and a CredentialManager.
+func Observers(observers ...Observer) Observer {
+	return multiObserver(observers)
+}
+
+type multiObserver []Observer
+
+func (m multiObserver) ObserveValidation(event ValidationEvent) {
+	for _, observer := range m {
+		observer.ObserveValidation(event)
+	}
+}
+
+func (m multiObserver) ObserveSigning(event SigningEvent) {
+	for _, observer := range m {
+		observer.ObserveSigning(event)
+	}
+}
+
+// reasonLabel returns the metric label for a validation outcome.
+func reasonLabel(reason Reason) string {
+	if reason == "" {
+		return "ok"
+	}
+
+	return string(reason)
+}
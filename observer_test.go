@@ -0,0 +1,157 @@
+package hmac
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordingObserver struct {
+	mu          sync.Mutex
+	validations []ValidationEvent
+	signings    []SigningEvent
+}
+
+func (o *recordingObserver) ObserveValidation(event ValidationEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.validations = append(o.validations, event)
+}
+
+func (o *recordingObserver) ObserveSigning(event SigningEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.signings = append(o.signings, event)
+}
+
+func TestThatObserverReceivesValidationEvents(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	observer := &recordingObserver{}
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithObserver(observer))
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	authenticator.Validate(signedRequest)
+
+	forgedRequest := signedTestRequest(t, publicKey, privateKey)
+	forgedRequest.Header.Set("Signature", "invalid signature")
+	authenticator.Validate(forgedRequest)
+
+	if len(observer.validations) != 2 {
+		t.Fatalf("expected 2 validation events, got %d", len(observer.validations))
+	}
+	valid, invalid := observer.validations[0], observer.validations[1]
+	if valid.Reason != "" || valid.Credential != publicKey || valid.BodySize != len(`{"foo": "bar"}`) {
+		t.Fatalf("unexpected event for valid request: %+v", valid)
+	}
+	if invalid.Reason != ReasonInvalidSignature || invalid.Credential != "" {
+		t.Fatalf("unexpected event for invalid request: %+v", invalid)
+	}
+}
+
+func TestThatObserverOmitsUnknownCredential(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	observer := &recordingObserver{}
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithObserver(observer))
+
+	signedRequest := signedTestRequest(t, GenerateSecureRandom(16), privateKey)
+	authenticator.Validate(signedRequest)
+
+	event := observer.validations[0]
+	if event.Reason != ReasonUnknownCredential || event.Credential != "" {
+		t.Fatalf("unexpected event for unknown credential: %+v", event)
+	}
+}
+
+func TestThatObserverReceivesSigningEvents(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	observer := &recordingObserver{}
+	requestService, _ := NewRequestService(publicKey, privateKey, WithSigningObserver(observer))
+
+	request, _ := http.NewRequest(http.MethodPost, "http://localhost:8080", strings.NewReader("content"))
+	requestService.SignRequest(request)
+
+	if len(observer.signings) != 1 {
+		t.Fatalf("expected 1 signing event, got %d", len(observer.signings))
+	}
+	event := observer.signings[0]
+	if event.Credential != publicKey || event.BodySize != len("content") || event.Err != nil {
+		t.Fatalf("unexpected signing event: %+v", event)
+	}
+}
+
+func TestThatPrometheusObserverServesMetrics(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	observer := NewPrometheusObserver()
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithObserver(observer))
+
+	forgedRequest := signedTestRequest(t, publicKey, privateKey)
+	forgedRequest.Header.Set("Signature", "invalid signature")
+	authenticator.Validate(forgedRequest)
+
+	recorder := httptest.NewRecorder()
+	observer.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+
+	expected := []string{
+		"# TYPE hmac_validations_total counter",
+		`hmac_validations_total{credential="",reason="invalid_signature"} 1`,
+		`hmac_validation_duration_seconds_bucket{le="+Inf"} 1`,
+		"hmac_validation_duration_seconds_count 1",
+		"hmac_validated_body_bytes_total 14",
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("expected metrics to contain %q, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestThatExpvarObserverPublishesCounters(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	observer := NewExpvarObserver("hmac_test")
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithObserver(observer))
+
+	signedRequest := signedTestRequest(t, publicKey, privateKey)
+	authenticator.Validate(signedRequest)
+
+	m := expvar.Get("hmac_test").(*expvar.Map)
+	validations := m.Get("validations").(*expvar.Map)
+	if validations.Get("ok").String() != "1" {
+		t.Fatalf("expected 1 ok validation, got %s", validations.Get("ok"))
+	}
+	credentials := m.Get("credentials").(*expvar.Map)
+	if credentials.Get(publicKey).(*expvar.Map).Get("ok").String() != "1" {
+		t.Fatalf("expected 1 ok validation for credential, got %s", credentials.Get(publicKey))
+	}
+}
+
+func TestThatObserversNotifiesEveryObserver(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	first, second := &recordingObserver{}, &recordingObserver{}
+
+	requestService, _ := NewRequestService(publicKey, privateKey, WithSigningObserver(Observers(first, second)))
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithObserver(Observers(first, second)))
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+	requestService.SignRequest(request)
+	authenticator.Validate(request)
+
+	for _, observer := range []*recordingObserver{first, second} {
+		if len(observer.validations) != 1 || len(observer.signings) != 1 {
+			t.Fatalf("expected 1 validation and 1 signing event, got %d and %d", len(observer.validations), len(observer.signings))
+		}
+	}
+}
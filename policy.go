@@ -0,0 +1,125 @@
+package hmac
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policy restricts the requests a credential may make. It is evaluated by
+// the Authenticator after the signature is verified; requests it does not
+// allow are rejected with ReasonForbiddenByPolicy. Empty fields allow
+// everything.
+type Policy struct {
+	// Methods are the allowed HTTP methods.
+	Methods []string
+	// Paths are the allowed paths as http.ServeMux patterns, such as
+	// "/items/{id}" or "GET /items/". A pattern that is invalid for
+	// http.ServeMux allows nothing.
+	Paths []string
+	// Hosts are the allowed hosts of the request, with or without port.
+	Hosts []string
+	// Networks are the allowed ranges of the remote address of the request.
+	// Behind a reverse proxy, the remote address is the proxy's.
+	Networks []netip.Prefix
+	// NotBefore and NotAfter bound the time the credential may be used.
+	NotBefore time.Time
+	NotAfter  time.Time
+
+	once sync.Once
+	mux  *http.ServeMux
+	err  error
+}
+
+// policyMatch is the handler of the patterns of a Policy.
+type policyMatch struct{}
+
+func (*policyMatch) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+var matched = &policyMatch{}
+
+// Allow returns an error describing the first rule of the policy the request
+// does not satisfy at time now, or nil if the request is allowed.
+func (p *Policy) Allow(r *http.Request, now time.Time) error {
+	if !p.NotBefore.IsZero() && now.Before(p.NotBefore) {
+		return fmt.Errorf("credential not valid before %s", p.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if !p.NotAfter.IsZero() && now.After(p.NotAfter) {
+		return fmt.Errorf("credential not valid after %s", p.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	if len(p.Methods) > 0 && !slices.ContainsFunc(p.Methods, func(method string) bool {
+		return strings.EqualFold(method, r.Method)
+	}) {
+		return fmt.Errorf("method %s not allowed", r.Method)
+	}
+
+	if len(p.Hosts) > 0 && !p.allowsHost(r.Host) {
+		return fmt.Errorf("host %s not allowed", r.Host)
+	}
+
+	if len(p.Networks) > 0 && !p.allowsRemoteAddr(r.RemoteAddr) {
+		return fmt.Errorf("remote address %s not allowed", r.RemoteAddr)
+	}
+
+	if len(p.Paths) > 0 {
+		p.once.Do(p.compilePaths)
+		if p.err != nil {
+			return p.err
+		}
+		if h, _ := p.mux.Handler(r); h != http.Handler(matched) {
+			return fmt.Errorf("path %s not allowed", r.URL.Path)
+		}
+	}
+
+	return nil
+}
+
+// validate returns an error if a path of the policy is not a valid
+// http.ServeMux pattern.
+func (p *Policy) validate() error {
+	p.once.Do(p.compilePaths)
+	return p.err
+}
+
+func (p *Policy) compilePaths() {
+	defer func() {
+		if r := recover(); r != nil {
+			p.err = fmt.Errorf("invalid policy path: %v", r)
+		}
+	}()
+
+	mux := http.NewServeMux()
+	for _, pattern := range p.Paths {
+		mux.Handle(pattern, matched)
+	}
+	p.mux = mux
+}
+
+func (p *Policy) allowsHost(host string) bool {
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+
+	return slices.ContainsFunc(p.Hosts, func(allowed string) bool {
+		return strings.EqualFold(allowed, host) || strings.EqualFold(allowed, hostname)
+	})
+}
+
+func (p *Policy) allowsRemoteAddr(remoteAddr string) bool {
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+
+	return slices.ContainsFunc(p.Networks, func(network netip.Prefix) bool {
+		return network.Contains(addr)
+	})
+}
@@ -0,0 +1,73 @@
+package hmac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestThatPolicyAllowsMatchingRequests(t *testing.T) {
+	policy := &Policy{
+		Methods:   []string{"GET", "POST"},
+		Paths:     []string{"/items/{id}", "POST /orders"},
+		Hosts:     []string{"api.example.com"},
+		Networks:  []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		NotBefore: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:  time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		method     string
+		target     string
+		remoteAddr string
+		at         time.Time
+		allowed    bool
+	}{
+		{"GET", "http://api.example.com/items/1", "10.1.2.3:1234", now, true},
+		{"POST", "http://api.example.com:8080/orders", "10.1.2.3:1234", now, true},
+		{"DELETE", "http://api.example.com/items/1", "10.1.2.3:1234", now, false},
+		{"GET", "http://api.example.com/orders", "10.1.2.3:1234", now, false},
+		{"GET", "http://api.example.com/items/1/notes", "10.1.2.3:1234", now, false},
+		{"GET", "http://other.example.com/items/1", "10.1.2.3:1234", now, false},
+		{"GET", "http://api.example.com/items/1", "192.168.1.1:1234", now, false},
+		{"GET", "http://api.example.com/items/1", "10.1.2.3:1234", now.AddDate(1, 0, 0), false},
+		{"GET", "http://api.example.com/items/1", "10.1.2.3:1234", now.AddDate(-1, 0, 0), false},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(test.method, test.target, nil)
+		request.RemoteAddr = test.remoteAddr
+
+		err := policy.Allow(request, test.at)
+
+		if (err == nil) != test.allowed {
+			t.Errorf("%s %s from %s at %s: expected allowed %v, got %v", test.method, test.target, test.remoteAddr, test.at, test.allowed, err)
+		}
+	}
+}
+
+func TestThatPolicyWithInvalidPatternAllowsNothing(t *testing.T) {
+	policy := &Policy{Paths: []string{"/items/{"}}
+
+	if err := policy.Allow(httptest.NewRequest(http.MethodGet, "/items/1", nil), time.Now()); err == nil {
+		t.Fatal("expected invalid pattern to deny request")
+	}
+}
+
+func TestThatAuthenticateRejectsRequestForbiddenByPolicy(t *testing.T) {
+	clientPublicKey := GenerateSecureRandom(16)
+	clientPrivateKey := GenerateSecureRandom(16)
+	credential, _ := NewCredential(clientPublicKey, clientPrivateKey)
+	credential.Policy = &Policy{Methods: []string{http.MethodGet}}
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300,
+		WithCredentialStore(memoryCredentialStore{clientPublicKey: credential}))
+
+	_, err := authenticator.Authenticate(signedTestRequest(t, clientPublicKey, clientPrivateKey))
+
+	assertValidationError(t, err, "Forbidden by policy: method POST not allowed")
+	if err.(*ValidationError).Reason != ReasonForbiddenByPolicy {
+		t.Fatalf("expected reason %s, got %s", ReasonForbiddenByPolicy, err.(*ValidationError).Reason)
+	}
+}
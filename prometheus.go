@@ -0,0 +1,166 @@
+package hmac
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDurationBuckets are the upper bounds, in seconds, of the duration
+// histograms exposed by PrometheusObserver.
+var DefaultDurationBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// PrometheusObserver is an Observer that serves its metrics in the
+// Prometheus text exposition format, without depending on the Prometheus
+// client library. Mount it on a metrics endpoint:
+//
+//	observer := hmac.NewPrometheusObserver()
+//	http.Handle("/metrics", observer)
+//
+// It exposes:
+//
+//	hmac_validations_total{credential,reason}        counter
+//	hmac_validation_duration_seconds                 histogram
+//	hmac_nonce_store_duration_seconds                histogram
+//	hmac_validated_body_bytes_total                  counter
+//	hmac_signings_total{credential,result}           counter
+//	hmac_signing_duration_seconds                    histogram
+type PrometheusObserver struct {
+	mu                 sync.Mutex
+	validations        map[[2]string]uint64
+	validationDuration *histogram
+	nonceStoreDuration *histogram
+	bodyBytes          uint64
+	signings           map[[2]string]uint64
+	signingDuration    *histogram
+}
+
+func NewPrometheusObserver() *PrometheusObserver {
+	return &PrometheusObserver{
+		validations:        make(map[[2]string]uint64),
+		validationDuration: newHistogram(DefaultDurationBuckets),
+		nonceStoreDuration: newHistogram(DefaultDurationBuckets),
+		signings:           make(map[[2]string]uint64),
+		signingDuration:    newHistogram(DefaultDurationBuckets),
+	}
+}
+
+func (o *PrometheusObserver) ObserveValidation(event ValidationEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.validations[[2]string{event.Credential, reasonLabel(event.Reason)}]++
+	o.validationDuration.observe(event.Duration)
+	if event.NonceStoreDuration > 0 {
+		o.nonceStoreDuration.observe(event.NonceStoreDuration)
+	}
+	o.bodyBytes += uint64(event.BodySize)
+}
+
+func (o *PrometheusObserver) ObserveSigning(event SigningEvent) {
+	result := "ok"
+	if event.Err != nil {
+		result = "error"
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.signings[[2]string{event.Credential, result}]++
+	o.signingDuration.observe(event.Duration)
+}
+
+// ServeHTTP writes the current metrics in the text exposition format.
+func (o *PrometheusObserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	o.WriteTo(w)
+}
+
+// WriteTo writes the current metrics in the text exposition format to w.
+func (o *PrometheusObserver) WriteTo(w io.Writer) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var b strings.Builder
+
+	writeCounterVec(&b, "hmac_validations_total", "Requests validated, by credential and outcome.", [2]string{"credential", "reason"}, o.validations)
+	o.validationDuration.write(&b, "hmac_validation_duration_seconds", "Time spent validating requests.")
+	o.nonceStoreDuration.write(&b, "hmac_nonce_store_duration_seconds", "Time spent in nonce store calls.")
+	writeMetricHeader(&b, "hmac_validated_body_bytes_total", "Total size of validated request bodies.", "counter")
+	fmt.Fprintf(&b, "hmac_validated_body_bytes_total %d\n", o.bodyBytes)
+	writeCounterVec(&b, "hmac_signings_total", "Requests signed, by credential and result.", [2]string{"credential", "result"}, o.signings)
+	o.signingDuration.write(&b, "hmac_signing_duration_seconds", "Time spent signing requests.")
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func writeMetricHeader(b *strings.Builder, name string, help string, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeCounterVec(b *strings.Builder, name string, help string, labels [2]string, values map[[2]string]uint64) {
+	writeMetricHeader(b, name, help, "counter")
+
+	keys := make([][2]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(x, y [2]string) int {
+		if c := strings.Compare(x[0], y[0]); c != 0 {
+			return c
+		}
+		return strings.Compare(x[1], y[1])
+	})
+
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s{%s=\"%s\",%s=\"%s\"} %d\n", name, labels[0], escapeLabelValue(k[0]), labels[1], escapeLabelValue(k[1]), values[k])
+	}
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(b *strings.Builder, name string, help string) {
+	writeMetricHeader(b, name, help, "histogram")
+
+	for i, bound := range h.bounds {
+		fmt.Fprintf(b, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(b, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(b, "%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(b, "%s_count %d\n", name, h.count)
+}
@@ -0,0 +1,107 @@
+package hmac
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// DefaultCredentialHeader is the header in which NewReverseProxy forwards
+// the authenticated credential to the upstream.
+const DefaultCredentialHeader = "X-Authenticated-Credential"
+
+type reverseProxy struct {
+	credentialHeader string
+	upstreamSigner   *RequestService
+}
+
+type ReverseProxyOption func(*reverseProxy)
+
+// WithCredentialHeader sets the header in which the authenticated credential
+// is forwarded. The default is DefaultCredentialHeader.
+func WithCredentialHeader(name string) ReverseProxyOption {
+	return func(p *reverseProxy) {
+		p.credentialHeader = name
+	}
+}
+
+// WithUpstreamSigner re-signs forwarded requests with signer, so that the
+// upstream can verify them with its own credential.
+func WithUpstreamSigner(signer *RequestService) ReverseProxyOption {
+	return func(p *reverseProxy) {
+		p.upstreamSigner = signer
+	}
+}
+
+// NewReverseProxy returns a handler that validates requests with
+// authenticator and forwards valid ones to target, for services that cannot
+// validate signatures themselves. The signature headers are stripped and the
+// authenticated credential is forwarded in a trusted header, overwriting any
+// value sent by the client. The upstream must only be reachable through the
+// proxy for that header to be trusted.
+func NewReverseProxy(target *url.URL, authenticator *Authenticator, options ...ReverseProxyOption) http.Handler {
+	p := &reverseProxy{
+		credentialHeader: DefaultCredentialHeader,
+	}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+
+			for _, h := range authenticator.headerNames.list() {
+				pr.Out.Header.Del(h)
+			}
+			pr.Out.Header.Del(p.credentialHeader)
+			if result, ok := FromContext(pr.In.Context()); ok {
+				pr.Out.Header.Set(p.credentialHeader, result.Credential)
+			}
+		},
+	}
+	if p.upstreamSigner != nil {
+		proxy.Transport = &signingTransport{
+			signer: func(host string) *RequestService {
+				return p.upstreamSigner
+			},
+		}
+	}
+
+	return authenticator.Middleware(proxy)
+}
+
+// signingTransport signs requests with the RequestService returned by
+// signer for their host before sending them with base, or
+// http.DefaultTransport if base is nil.
+type signingTransport struct {
+	signer func(host string) *RequestService
+	base   http.RoundTripper
+}
+
+func (t *signingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	if request.Host == "" {
+		request.Host = request.URL.Host
+	}
+
+	signer := t.signer(request.URL.Host)
+	if signer == nil {
+		return nil, fmt.Errorf("no credentials for host %s", request.URL.Host)
+	}
+
+	signedRequest, err := signer.SignRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(signedRequest)
+}
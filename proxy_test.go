@@ -0,0 +1,114 @@
+package hmac
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestThatReverseProxyForwardsAuthenticatedCredential(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	var received *http.Request
+	var receivedBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ := io.ReadAll(r.Body)
+		receivedBody = string(body)
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	proxy := httptest.NewServer(NewReverseProxy(target, authenticator))
+	defer proxy.Close()
+
+	request, _ := http.NewRequest(http.MethodPost, proxy.URL+"/items?abc=xyz", strings.NewReader("payload"))
+	request.Header.Set(DefaultCredentialHeader, "spoofed")
+	requestService, _ := NewRequestService(publicKey, privateKey)
+	requestService.SignRequest(request)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK || received == nil {
+		t.Fatalf("expected request to be forwarded, got status %d", response.StatusCode)
+	}
+	if received.Header.Get(DefaultCredentialHeader) != publicKey {
+		t.Fatalf("expected credential %q, got %q", publicKey, received.Header.Get(DefaultCredentialHeader))
+	}
+	if received.Header.Get("Signature") != "" || received.Header.Get("Credential") != "" {
+		t.Fatal("expected auth headers to be stripped")
+	}
+	if received.URL.RequestURI() != "/items?abc=xyz" || receivedBody != "payload" {
+		t.Fatalf("unexpected forwarded request %s %q", received.URL.RequestURI(), receivedBody)
+	}
+}
+
+func TestThatReverseProxyRejectsInvalidRequest(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	forwarded := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = true
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	proxy := httptest.NewServer(NewReverseProxy(target, authenticator))
+	defer proxy.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, proxy.URL, nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if forwarded || response.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected request to be rejected, got status %d", response.StatusCode)
+	}
+}
+
+func TestThatReverseProxyResignsForUpstream(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	upstreamPublicKey := GenerateSecureRandom(16)
+	upstreamPrivateKey := GenerateSecureRandom(16)
+
+	upstreamAuthenticator, _ := NewAuthenticator(upstreamPublicKey, upstreamPrivateKey, 300)
+	var upstreamResult *Result
+	upstream := httptest.NewServer(upstreamAuthenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamResult, _ = FromContext(r.Context())
+	})))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	upstreamSigner, _ := NewRequestService(upstreamPublicKey, upstreamPrivateKey)
+	proxy := httptest.NewServer(NewReverseProxy(target, authenticator, WithUpstreamSigner(upstreamSigner)))
+	defer proxy.Close()
+
+	request, _ := http.NewRequest(http.MethodPost, proxy.URL+"/items", strings.NewReader("payload"))
+	requestService, _ := NewRequestService(publicKey, privateKey)
+	requestService.SignRequest(request)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK || upstreamResult == nil || upstreamResult.Credential != upstreamPublicKey {
+		t.Fatalf("expected upstream to authenticate re-signed request, got status %d", response.StatusCode)
+	}
+}
@@ -0,0 +1,191 @@
+package hmac
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket limit: Requests per Period on average, with
+// bursts of up to Burst requests. Burst defaults to Requests.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+func (l RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+
+	return l.Requests
+}
+
+// RateLimitStatus is the outcome of a RateLimiter decision.
+type RateLimitStatus struct {
+	Allowed bool
+	// Limit is the size of the bucket and Remaining the requests left in it.
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, when the
+	// request is not.
+	RetryAfter time.Duration
+}
+
+// RateLimiter takes a request of credential from its bucket. Implementations
+// backed by shared storage let several servers enforce a common limit.
+type RateLimiter interface {
+	Allow(credential string, limit RateLimit) (RateLimitStatus, error)
+}
+
+// WithRateLimiter limits the requests of each credential with limiter, once
+// they are otherwise valid. Credentials without a RateLimit are limited by
+// defaultLimit; a zero defaultLimit leaves them unlimited. Requests over the
+// limit are rejected with status 429 and reason ReasonRateLimited, so that
+// they are audited and observed as rejected. Middleware sets a Retry-After
+// header on them, and RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers on every limited response. Requests are allowed if
+// limiter fails.
+func WithRateLimiter(limiter RateLimiter, defaultLimit RateLimit) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.rateLimiter = limiter
+		a.defaultRateLimit = defaultLimit
+	}
+}
+
+// rateLimit applies the rate limit of the credential of result, recording
+// its status in result, and returns a ValidationError if the request is over
+// the limit.
+func (a *Authenticator) rateLimit(r *http.Request, result *Result) *ValidationError {
+	limit := a.defaultRateLimit
+	if result.rateLimit != nil {
+		limit = *result.rateLimit
+	}
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return nil
+	}
+
+	status, err := a.rateLimiter.Allow(result.Credential, limit)
+	if err != nil {
+		if a.logger != nil {
+			a.logger.LogAttrs(r.Context(), slog.LevelError, "rate limiter failed",
+				slog.String("credential", result.Credential),
+				slog.String("error", err.Error()),
+			)
+		}
+		return nil
+	}
+
+	if !status.Allowed {
+		return &ValidationError{
+			Code:      http.StatusTooManyRequests,
+			Reason:    ReasonRateLimited,
+			Message:   "Rate limit exceeded",
+			rateLimit: &status,
+		}
+	}
+	result.rateLimitStatus = &status
+
+	return nil
+}
+
+// writeRateLimitHeaders sets the rate limit headers of status, if any.
+func writeRateLimitHeaders(w http.ResponseWriter, status *RateLimitStatus) {
+	if status == nil {
+		return
+	}
+
+	header := w.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(status.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(status.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(seconds(status.Reset)))
+	if !status.Allowed {
+		header.Set("Retry-After", strconv.Itoa(seconds(status.RetryAfter)))
+	}
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket is full again if no request is made.
+	full time.Time
+}
+
+// rateLimiterPruneInterval is how often MemoryRateLimiter drops full buckets.
+const rateLimiterPruneInterval = time.Minute
+
+// MemoryRateLimiter is an in-memory RateLimiter. Limits only apply to the
+// process it runs in.
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+	now     func() time.Time
+}
+
+// NewMemoryRateLimiter returns an empty MemoryRateLimiter.
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow implements RateLimiter.
+func (l *MemoryRateLimiter) Allow(credential string, limit RateLimit) (RateLimitStatus, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	burst := float64(limit.burst())
+	rate := float64(limit.Requests) / limit.Period.Seconds()
+
+	b, ok := l.buckets[credential]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[credential] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	status := RateLimitStatus{Limit: limit.burst()}
+	if b.tokens >= 1 {
+		b.tokens--
+		status.Allowed = true
+	} else {
+		status.RetryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	status.Remaining = int(b.tokens)
+	status.Reset = time.Duration((burst - b.tokens) / rate * float64(time.Second))
+	b.full = now.Add(status.Reset)
+
+	l.prune(now)
+
+	return status, nil
+}
+
+// prune drops the buckets that are full again, at most once per
+// rateLimiterPruneInterval. They are recreated full on demand.
+func (l *MemoryRateLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < rateLimiterPruneInterval {
+		return
+	}
+	l.pruned = now
+
+	for credential, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, credential)
+		}
+	}
+}
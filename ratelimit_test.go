@@ -0,0 +1,151 @@
+package hmac
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestThatMemoryRateLimiterRefillsTokens(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewMemoryRateLimiter()
+	limiter.now = func() time.Time { return now }
+	limit := RateLimit{Requests: 2, Period: time.Second}
+
+	for i := 0; i < 2; i++ {
+		if status, _ := limiter.Allow("client", limit); !status.Allowed {
+			t.Fatalf("expected request %d to be allowed", i)
+		}
+	}
+	status, _ := limiter.Allow("client", limit)
+	if status.Allowed || status.Remaining != 0 || status.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("expected request to be limited for 500ms, got %+v", status)
+	}
+
+	if status, _ := limiter.Allow("other", limit); !status.Allowed {
+		t.Fatal("expected other credential to have its own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if status, _ := limiter.Allow("client", limit); !status.Allowed {
+		t.Fatalf("expected request to be allowed after refill, got %+v", status)
+	}
+}
+
+func TestThatMiddlewareRejectsRequestsOverRateLimit(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300,
+		WithRateLimiter(NewMemoryRateLimiter(), RateLimit{Requests: 1, Period: time.Minute}))
+	handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, signedTestRequest(t, publicKey, privateKey))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("RateLimit-Limit") != "1" || recorder.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("expected status 200 with rate limit headers, got %d, %v", recorder.Code, recorder.Header())
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, signedTestRequest(t, publicKey, privateKey))
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") != "60" {
+		t.Fatalf("expected status 429 with Retry-After 60, got %d, %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestThatCredentialRateLimitOverridesDefault(t *testing.T) {
+	clientPublicKey := GenerateSecureRandom(16)
+	clientPrivateKey := GenerateSecureRandom(16)
+	credential, _ := NewCredential(clientPublicKey, clientPrivateKey)
+	credential.RateLimit = &RateLimit{Requests: 10, Period: time.Minute}
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300,
+		WithCredentialStore(memoryCredentialStore{clientPublicKey: credential}),
+		WithRateLimiter(NewMemoryRateLimiter(), RateLimit{Requests: 1, Period: time.Minute}))
+	handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, signedTestRequest(t, clientPublicKey, clientPrivateKey))
+		if recorder.Code != http.StatusOK || recorder.Header().Get("RateLimit-Limit") != "10" {
+			t.Fatalf("expected status 200 with limit 10, got %d, %v", recorder.Code, recorder.Header())
+		}
+	}
+}
+
+type failingRateLimiter struct{}
+
+func (failingRateLimiter) Allow(string, RateLimit) (RateLimitStatus, error) {
+	return RateLimitStatus{}, errors.New("unavailable")
+}
+
+func TestThatMiddlewareAllowsRequestsWhenRateLimiterFails(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300,
+		WithRateLimiter(failingRateLimiter{}, RateLimit{Requests: 1, Period: time.Minute}))
+	handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, signedTestRequest(t, publicKey, privateKey))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+}
+
+func TestThatMemoryRateLimiterKeepsBucketsUntilFull(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewMemoryRateLimiter()
+	limiter.now = func() time.Time { return now }
+	slow := RateLimit{Requests: 1, Period: time.Hour}
+	burst := RateLimit{Requests: 1, Period: time.Minute, Burst: 5}
+	fast := RateLimit{Requests: 1, Period: 50 * time.Millisecond}
+
+	limiter.Allow("slow", slow)
+	for range 5 {
+		limiter.Allow("burst", burst)
+	}
+	for range 3 {
+		now = now.Add(time.Minute)
+		limiter.Allow("fast", fast)
+	}
+
+	if status, _ := limiter.Allow("slow", slow); status.Allowed {
+		t.Fatalf("expected slow credential to remain limited, got %+v", status)
+	}
+	if status, _ := limiter.Allow("burst", burst); !status.Allowed || status.Remaining != 2 {
+		t.Fatalf("expected burst credential to have refilled 3 of 5 tokens, got %+v", status)
+	}
+
+	now = now.Add(time.Hour)
+	limiter.Allow("fast", fast)
+	if len(limiter.buckets) != 1 {
+		t.Fatalf("expected full buckets to be pruned, got %d buckets", len(limiter.buckets))
+	}
+}
+
+func TestThatRateLimitedRequestsAreAuditedAndObservedAsRejected(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	sink := &recordingAuditSink{}
+	observer := &recordingObserver{}
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300,
+		WithAuditSink(sink),
+		WithObserver(observer),
+		WithRateLimiter(NewMemoryRateLimiter(), RateLimit{Requests: 1, Period: time.Minute}))
+
+	_, _ = authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey))
+	_, err := authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey))
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Reason != ReasonRateLimited || validationErr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected rate_limited error, got %v", err)
+	}
+	if len(sink.entries) != 2 || sink.entries[1].Outcome != string(ReasonRateLimited) {
+		t.Fatalf("expected second entry to be rate_limited, got %+v", sink.entries)
+	}
+	if len(observer.validations) != 2 || observer.validations[1].Reason != ReasonRateLimited {
+		t.Fatalf("expected second request to be observed as rate_limited, got %+v", observer.validations)
+	}
+}
@@ -2,61 +2,300 @@ package hmac
 
 import (
 	"bytes"
-	"encoding/hex"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 )
 
 type RequestService struct {
-	public  string
-	private []byte
+	public        string
+	private       SecretKey
+	signer        Signer
+	observer      Observer
+	logger        *slog.Logger
+	clock         func() time.Time
+	nonceLength   int
+	nonceSource   func() string
+	signedHeaders []string
+	headerNames   HeaderNames
+	scope         *Scope
 }
 
-func NewRequestService(public string, private string) (*RequestService, error) {
+type RequestServiceOption func(*RequestService)
+
+// WithSigningObserver reports every signed request to observer.
+func WithSigningObserver(observer Observer) RequestServiceOption {
+	return func(rs *RequestService) {
+		rs.observer = observer
+	}
+}
+
+// WithSigningLogger logs signing failures to logger at warning level and
+// signed requests at debug level. Keys and signatures are never logged.
+func WithSigningLogger(logger *slog.Logger) RequestServiceOption {
+	return func(rs *RequestService) {
+		rs.logger = logger
+	}
+}
+
+// WithSigningClock makes the RequestService timestamp requests with now
+// instead of time.Now.
+func WithSigningClock(now func() time.Time) RequestServiceOption {
+	return func(rs *RequestService) {
+		rs.clock = now
+	}
+}
+
+// WithSigningNonceLength sets the number of random bytes in generated nonces.
+// The default is 8.
+func WithSigningNonceLength(length int) RequestServiceOption {
+	return func(rs *RequestService) {
+		rs.nonceLength = length
+	}
+}
+
+// WithSigningNonceSource makes the RequestService take nonces from next
+// instead of generating random ones. next must not return the same nonce
+// twice to an Authenticator with a NonceStore; it is meant for tests that
+// need deterministic requests.
+func WithSigningNonceSource(next func() string) RequestServiceOption {
+	return func(rs *RequestService) {
+		rs.nonceSource = next
+	}
+}
+
+// WithSigningHeaders signs the values of the given headers in addition to
+// the signature headers, so that they cannot be changed in transit. The
+// names are sent in the X-Signed-Headers header and the Authenticator
+// includes the listed headers when validating. A header that is not set is
+// signed as empty.
+func WithSigningHeaders(names ...string) RequestServiceOption {
+	return func(rs *RequestService) {
+		rs.signedHeaders = append(rs.signedHeaders, names...)
+	}
+}
+
+// WithSigningHeaderNames sends the signature headers under the given names.
+// The Authenticator must be configured with the same names, see
+// WithHeaderNames.
+func WithSigningHeaderNames(names HeaderNames) RequestServiceOption {
+	return func(rs *RequestService) {
+		rs.headerNames = names.withDefaults()
+	}
+}
+
+// WithSigningScope signs requests with the key of their scope for the given
+// environment and service on the UTC day of their timestamp, so that they
+// can be validated with a key scoped to that service. See Scope.
+func WithSigningScope(environment string, service string) RequestServiceOption {
+	return func(rs *RequestService) {
+		rs.scope = &Scope{Environment: environment, Service: service}
+	}
+}
+
+// NewRequestService returns a RequestService signing with the key pair
+// public and private. The private key is parsed with ParseSecretKey.
+func NewRequestService(public string, private string, options ...RequestServiceOption) (*RequestService, error) {
 	if len(public) == 0 {
 		return nil, fmt.Errorf("public key required")
 	}
 
-	if len(private) == 0 {
-		return nil, fmt.Errorf("private key required")
+	key, err := ParseSecretKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := &RequestService{
+		public:      public,
+		private:     key,
+		clock:       time.Now,
+		nonceLength: 8,
+		headerNames: DefaultHeaderNames,
 	}
 
-	decodedPrivateKey, err := hex.DecodeString(private)
-	if err != nil {
-		return nil, fmt.Errorf("invalid private key")
+	for _, option := range options {
+		option(rs)
+	}
+
+	return rs, nil
+}
+
+// NewRequestServiceWithSigner returns a RequestService like NewRequestService
+// that signs requests with signer, so that the private key can be held by a
+// KMS or HSM. See NewKey.
+func NewRequestServiceWithSigner(public string, signer Signer, options ...RequestServiceOption) (*RequestService, error) {
+	if len(public) == 0 {
+		return nil, fmt.Errorf("public key required")
+	}
+
+	if signer == nil {
+		return nil, fmt.Errorf("signer required")
+	}
+
+	rs := &RequestService{
+		public:      public,
+		signer:      signer,
+		clock:       time.Now,
+		nonceLength: 8,
+		headerNames: DefaultHeaderNames,
+	}
+
+	for _, option := range options {
+		option(rs)
 	}
 
-	return &RequestService{public, decodedPrivateKey}, nil
+	return rs, nil
 }
 
 // SignRequest signs the request in place and returns it. The request body,
 // if any, is restored so it can still be read after signing.
 func (rs *RequestService) SignRequest(request *http.Request) (*http.Request, error) {
-	timestamp := time.Now().Unix()
+	return rs.observeSigning(request, rs.signRequest)
+}
+
+// SignClone signs a clone of the request and returns it, leaving the headers
+// of request unchanged. If request.GetBody is set, the clone reads its body
+// from it; otherwise the body of request is read and replaced with an
+// identical one.
+func (rs *RequestService) SignClone(request *http.Request) (*http.Request, error) {
+	return rs.observeSigning(request, func(request *http.Request, event *SigningEvent) (*http.Request, error) {
+		clone := request.Clone(request.Context())
+		switch {
+		case request.GetBody != nil:
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("unable to read body: %w", err)
+			}
+			clone.Body = body
+		case request.Body != nil:
+			content, err := io.ReadAll(request.Body)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read body: %w", err)
+			}
+			request.Body = io.NopCloser(bytes.NewReader(content))
+			clone.Body = io.NopCloser(bytes.NewReader(content))
+		}
+
+		return rs.signRequest(clone, event)
+	})
+}
+
+func (rs *RequestService) observeSigning(request *http.Request, signRequest func(*http.Request, *SigningEvent) (*http.Request, error)) (*http.Request, error) {
+	start := time.Now()
+	event := SigningEvent{Credential: rs.public}
+
+	signedRequest, err := signRequest(request, &event)
+
+	event.Duration = time.Since(start)
+	event.Err = err
+	if rs.observer != nil {
+		rs.observer.ObserveSigning(event)
+	}
+	if rs.logger != nil {
+		attrs := []slog.Attr{
+			slog.String("method", request.Method),
+			slog.String("path", request.URL.Path),
+			slog.String("credential", rs.public),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+			rs.logger.LogAttrs(request.Context(), slog.LevelWarn, "request signing failed", attrs...)
+		} else {
+			rs.logger.LogAttrs(request.Context(), slog.LevelDebug, "request signed", attrs...)
+		}
+	}
+
+	return signedRequest, err
+}
+
+// LogValue implements slog.LogValuer. The private key is never included.
+func (rs *RequestService) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("public", rs.public))
+}
+
+func (rs *RequestService) signRequest(request *http.Request, event *SigningEvent) (*http.Request, error) {
+	err := rs.sign(request.Header, &request.Body, func(headers map[string]string) string {
+		return CreateCanonicalRequestString(request.Method, request.Host, request.URL.Path, request.URL.RawQuery, headers)
+	}, event)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// sign sets the signature headers of a request or response with the given
+// header and body. canonical returns the canonical string of the message for
+// the signed header values.
+func (rs *RequestService) sign(header http.Header, body *io.ReadCloser, canonical func(headers map[string]string) string, event *SigningEvent) error {
+	timestamp := rs.clock().Unix()
 
 	var content []byte
-	if request.Body != nil {
+	if *body != nil {
 		var err error
-		content, err = io.ReadAll(request.Body)
+		content, err = io.ReadAll(*body)
 		if err != nil {
-			return nil, fmt.Errorf("unable to read request body: %w", err)
+			return fmt.Errorf("unable to read body: %w", err)
 		}
-		request.Body = io.NopCloser(bytes.NewReader(content))
+		*body = io.NopCloser(bytes.NewReader(content))
 	}
+	event.BodySize = len(content)
 
-	headers := BuildHeaders(timestamp, content)
+	headers := buildHeaders(timestamp, rs.nonce(), content)
+	if rs.scope != nil {
+		headers["X-Scope"] = NewScope(time.Unix(timestamp, 0), rs.scope.Environment, rs.scope.Service).String()
+	}
+	if signed := rs.signedHeaderNames(); len(signed) > 0 {
+		headers["X-Signed-Headers"] = strings.Join(signed, ",")
+		for _, name := range signed {
+			headers[name] = strings.Join(header.Values(name), ",")
+		}
+	}
 
-	canonicalRequest := CreateCanonicalRequestString(request.Method, request.Host, request.URL.Path, request.URL.RawQuery, headers)
+	canonicalString := canonical(headers)
 
 	headers["Authorization"] = "HMAC-SHA256"
 	headers["Credential"] = rs.public
-	headers["Signature"] = CreateSignature(canonicalRequest, timestamp, string(rs.private))
+	if rs.signer != nil {
+		signature, err := rs.signer.Sign(canonicalString, timestamp, headers["X-Scope"])
+		if err != nil {
+			return fmt.Errorf("unable to sign request: %w", err)
+		}
+		headers["Signature"] = signature
+	} else {
+		headers["Signature"] = createSignature(canonicalString, timestamp, rs.private.Bytes(), "", headers["X-Scope"])
+	}
+
+	for _, name := range DefaultHeaderNames.list() {
+		if value, ok := headers[name]; ok {
+			header.Set(rs.headerNames.name(name), value)
+		}
+	}
 
-	for name, value := range headers {
-		request.Header.Set(name, value)
+	return nil
+}
+
+func (rs *RequestService) nonce() string {
+	if rs.nonceSource != nil {
+		return rs.nonceSource()
 	}
 
-	return request, nil
+	return GenerateSecureRandom(rs.nonceLength)
+}
+
+// signedHeaderNames returns the headers configured with WithSigningHeaders,
+// except the signature headers under their configured names.
+func (rs *RequestService) signedHeaderNames() []string {
+	var signed []string
+	for _, name := range signedHeaderNames(rs.signedHeaders) {
+		if !slices.Contains(rs.headerNames.list(), name) {
+			signed = append(signed, name)
+		}
+	}
+
+	return signed
 }
@@ -3,8 +3,11 @@ package hmac
 import (
 	"bytes"
 	"io"
+	"log/slog"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestThatNewRequestServiceReturnsInstanceOfRequestService(t *testing.T) {
@@ -43,7 +46,7 @@ func TestThatNewRequestServiceReturnsErrorMissingPrivateKey(t *testing.T) {
 }
 
 func TestThatNewRequestServiceReturnsErrorInvalidPrivateKey(t *testing.T) {
-	errMsg := "invalid private key"
+	errMsg := "malformed private key"
 	publicKey := GenerateSecureRandom(16)
 	privateKey := "0"
 
@@ -99,3 +102,122 @@ func TestThatSignRequestPreservesRequestBody(t *testing.T) {
 		t.Fatalf("expected body %q after SignRequest, got %q", content, string(body))
 	}
 }
+
+func TestThatSigningLoggerOmitsSecrets(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/foo", nil)
+
+	requestService, _ := NewRequestService(publicKey, privateKey, WithSigningLogger(logger))
+	signedRequest, _ := requestService.SignRequest(request)
+	logger.Info("request service", "service", requestService)
+
+	output := buf.String()
+	if !strings.Contains(output, `"msg":"request signed"`) || !strings.Contains(output, `"path":"/foo"`) {
+		t.Fatalf("expected signed request to be logged, got %s", output)
+	}
+	if strings.Contains(output, privateKey) || strings.Contains(output, signedRequest.Header.Get("Signature")) {
+		t.Fatalf("expected no private key or signature in log, got %s", output)
+	}
+}
+
+func TestThatSignRequestUsesClockAndNonceOptions(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	requestService, _ := NewRequestService(publicKey, privateKey,
+		WithSigningClock(func() time.Time { return time.Unix(1700000000, 0) }),
+		WithSigningNonceSource(func() string { return "fixed-nonce" }))
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+
+	signedRequest, _ := requestService.SignRequest(request)
+
+	if signedRequest.Header.Get("X-Timestamp") != "1700000000" || signedRequest.Header.Get("X-Nonce") != "fixed-nonce" {
+		t.Fatalf("unexpected timestamp %q and nonce %q", signedRequest.Header.Get("X-Timestamp"), signedRequest.Header.Get("X-Nonce"))
+	}
+}
+
+func TestThatSignRequestUsesNonceLength(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	requestService, _ := NewRequestService(publicKey, privateKey, WithSigningNonceLength(16))
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+
+	signedRequest, _ := requestService.SignRequest(request)
+
+	if len(signedRequest.Header.Get("X-Nonce")) != 32 {
+		t.Fatalf("expected 16 byte nonce, got %q", signedRequest.Header.Get("X-Nonce"))
+	}
+}
+
+func TestThatSignedHeadersAreValidated(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	requestService, _ := NewRequestService(publicKey, privateKey, WithSigningHeaders("content-type", "Signature"))
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	request, _ := http.NewRequest(http.MethodPost, "http://localhost:8080", strings.NewReader(`{"foo": "bar"}`))
+	request.Header.Set("Content-Type", "application/json")
+
+	signedRequest, _ := requestService.SignRequest(request)
+	if signedRequest.Header.Get("X-Signed-Headers") != "Content-Type" {
+		t.Fatalf("expected X-Signed-Headers Content-Type, got %q", signedRequest.Header.Get("X-Signed-Headers"))
+	}
+	result, err := authenticator.Authenticate(signedRequest)
+	if err != nil || result.SignedHeaders["Content-Type"] != "application/json" {
+		t.Fatalf("expected valid request with signed Content-Type, got %v, %v", result, err)
+	}
+
+	signedRequest, _ = requestService.SignRequest(request)
+	signedRequest.Header.Set("Content-Type", "text/plain")
+	_, err = authenticator.Authenticate(signedRequest)
+	assertValidationError(t, err, "Not authorized")
+}
+
+func TestThatCustomHeaderNamesAreValidated(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	names := HeaderNames{Signature: "X-Hmac-Signature", Credential: "X-Hmac-Credential"}
+	requestService, _ := NewRequestService(publicKey, privateKey, WithSigningHeaderNames(names))
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+
+	signedRequest, _ := requestService.SignRequest(request)
+	if signedRequest.Header.Get("Signature") != "" || signedRequest.Header.Get("X-Hmac-Signature") == "" {
+		t.Fatalf("expected signature in X-Hmac-Signature, got %v", signedRequest.Header)
+	}
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithHeaderNames(names))
+	if _, err := authenticator.Authenticate(signedRequest); err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, _ = NewAuthenticator(publicKey, privateKey, 300)
+	_, err := authenticator.Authenticate(signedRequest)
+	assertValidationError(t, err, "Credential is a required header")
+}
+
+func TestThatSignCloneLeavesRequestUnchanged(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	requestService, _ := NewRequestService(publicKey, privateKey)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	request, _ := http.NewRequest(http.MethodPost, "http://localhost:8080", strings.NewReader("payload"))
+
+	clone, err := requestService.SignClone(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if request.Header.Get("Signature") != "" {
+		t.Fatal("expected original request to be unsigned")
+	}
+	body, _ := io.ReadAll(request.Body)
+	if string(body) != "payload" {
+		t.Fatalf("expected original body to be readable, got %q", body)
+	}
+	if _, err := authenticator.Authenticate(clone); err != nil {
+		t.Fatal(err)
+	}
+}
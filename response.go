@@ -0,0 +1,92 @@
+package hmac
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// SignResponse signs the response in place and returns it, so that the
+// client can verify it with Authenticator.AuthenticateResponse. When
+// response.Request is set, its X-Nonce header is included in the signature,
+// binding the response to the request it answers. The response body, if
+// any, is restored so it can still be read after signing.
+func (rs *RequestService) SignResponse(response *http.Response) (*http.Response, error) {
+	var event SigningEvent
+	err := rs.sign(response.Header, &response.Body, func(headers map[string]string) string {
+		return CreateCanonicalResponseString(response.StatusCode, requestNonce(response.Request, rs.headerNames), headers)
+	}, &event)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// AuthenticateResponse validates a response signed with SignResponse. When
+// response.Request is set, the response must answer that request. Unlike
+// Authenticate, it does not consult the NonceStore, notify the Observer or
+// write to the log or audit sink. The response body is restored so callers
+// can still read it after validation.
+func (a *Authenticator) AuthenticateResponse(response *http.Response) (*Result, error) {
+	var event ValidationEvent
+	return a.verify(response.Header, &response.Body, func(headers map[string]string) string {
+		return CreateCanonicalResponseString(response.StatusCode, requestNonce(response.Request, a.headerNames), headers)
+	}, &event)
+}
+
+// SigningMiddleware signs every response written by next. Responses are
+// buffered in memory until next returns, so it is not suitable for streaming
+// responses.
+func (rs *RequestService) SigningMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buffer := &bufferedResponseWriter{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buffer, r)
+
+		response := &http.Response{
+			StatusCode: buffer.status,
+			Header:     buffer.header,
+			Body:       io.NopCloser(bytes.NewReader(buffer.body.Bytes())),
+			Request:    r,
+		}
+		if _, err := rs.SignResponse(response); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(buffer.status)
+		w.Write(buffer.body.Bytes())
+	})
+}
+
+type bufferedResponseWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponseWriter) WriteHeader(status int) {
+	if b.wroteHeader {
+		return
+	}
+	b.status = status
+	b.wroteHeader = true
+}
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
+
+func requestNonce(request *http.Request, names HeaderNames) string {
+	if request == nil {
+		return ""
+	}
+
+	return request.Header.Get(names.Nonce)
+}
@@ -0,0 +1,117 @@
+package hmac
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func signedTestResponse(t *testing.T, request *http.Request, publicKey string, privateKey string) *http.Response {
+	t.Helper()
+
+	response := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(`{"ok": true}`)),
+		Request:    request,
+	}
+
+	requestService, _ := NewRequestService(publicKey, privateKey)
+	signedResponse, err := requestService.SignResponse(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signedResponse
+}
+
+func TestThatAuthenticateResponseAcceptsSignedResponse(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	request := signedTestRequest(t, GenerateSecureRandom(16), GenerateSecureRandom(16))
+	response := signedTestResponse(t, request, publicKey, privateKey)
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	result, err := authenticator.AuthenticateResponse(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Credential != publicKey {
+		t.Fatalf("expected credential %q, got %q", publicKey, result.Credential)
+	}
+
+	body, _ := io.ReadAll(response.Body)
+	if string(body) != `{"ok": true}` {
+		t.Fatalf("expected body to be restored, got %q", string(body))
+	}
+}
+
+func TestThatAuthenticateResponseRejectsTamperedStatus(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	request := signedTestRequest(t, GenerateSecureRandom(16), GenerateSecureRandom(16))
+	response := signedTestResponse(t, request, publicKey, privateKey)
+	response.StatusCode = http.StatusCreated
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	_, err := authenticator.AuthenticateResponse(response)
+
+	assertValidationError(t, err, "Not authorized")
+}
+
+func TestThatAuthenticateResponseRejectsResponseToOtherRequest(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	request := signedTestRequest(t, GenerateSecureRandom(16), GenerateSecureRandom(16))
+	response := signedTestResponse(t, request, publicKey, privateKey)
+	response.Request = signedTestRequest(t, GenerateSecureRandom(16), GenerateSecureRandom(16))
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	_, err := authenticator.AuthenticateResponse(response)
+
+	assertValidationError(t, err, "Not authorized")
+}
+
+func TestThatSigningMiddlewareSignsResponses(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	requestService, _ := NewRequestService(publicKey, privateKey)
+	handler := requestService.SigningMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "created")
+	}))
+
+	request := signedTestRequest(t, GenerateSecureRandom(16), GenerateSecureRandom(16))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	response := recorder.Result()
+	response.Request = request
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	if _, err := authenticator.AuthenticateResponse(response); err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, response.StatusCode)
+	}
+}
+
+func TestThatCanonicalResponseIsNeverCanonicalRequest(t *testing.T) {
+	headers := map[string]string{"X-Timestamp": "1", "X-Nonce": "abc"}
+
+	response := CreateCanonicalResponseString(http.StatusOK, "/admin", headers)
+
+	if response == CreateCanonicalRequestString("200", "", "/admin", "", headers) {
+		t.Fatal("expected response and request canonical strings to differ")
+	}
+	if !strings.HasPrefix(response, "RESPONSE\n") {
+		t.Fatalf("unexpected canonical response %q", response)
+	}
+}
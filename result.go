@@ -0,0 +1,55 @@
+package hmac
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Result describes a request that passed validation.
+type Result struct {
+	// Credential is the ID of the credential that signed the request.
+	Credential string
+	// Metadata is the metadata of the credential that signed the request.
+	Metadata map[string]string
+	// Algorithm is the value of the Authorization header.
+	Algorithm string
+	// Timestamp is the signing time sent in the X-Timestamp header.
+	Timestamp time.Time
+	// Nonce is the value of the X-Nonce header.
+	Nonce string
+	// SignedHeaders holds the headers covered by the signature.
+	SignedHeaders map[string]string
+	// ContentHash is the verified X-Content-SHA256 value. It is empty when
+	// the request has no body.
+	ContentHash string
+
+	policy          *Policy
+	rateLimit       *RateLimit
+	rateLimitStatus *RateLimitStatus
+}
+
+// LogValue implements slog.LogValuer.
+func (r *Result) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("credential", r.Credential),
+		slog.String("algorithm", r.Algorithm),
+		slog.Time("timestamp", r.Timestamp),
+		slog.String("nonce", r.Nonce),
+		slog.String("content_hash", r.ContentHash),
+	)
+}
+
+type resultKey struct{}
+
+// NewContext returns a copy of ctx that carries result.
+func NewContext(ctx context.Context, result *Result) context.Context {
+	return context.WithValue(ctx, resultKey{}, result)
+}
+
+// FromContext returns the Result stored in ctx, if any. Handlers wrapped by
+// Authenticator.Middleware can use it to identify the caller.
+func FromContext(ctx context.Context) (*Result, bool) {
+	result, ok := ctx.Value(resultKey{}).(*Result)
+	return result, ok
+}
@@ -0,0 +1,99 @@
+package hmac
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RevocationList reports whether a credential has been revoked.
+type RevocationList interface {
+	Revoked(id string) bool
+}
+
+// WithRevocationList rejects requests signed by credentials revoked in list
+// with ReasonCredentialRevoked. Unlike removing a credential from the
+// CredentialStore, revocation is reported as such to the client.
+func WithRevocationList(list RevocationList) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.revocations = list
+	}
+}
+
+// MemoryRevocationList is an in-memory RevocationList that can be updated at
+// runtime. It is safe for concurrent use.
+type MemoryRevocationList struct {
+	mu      sync.RWMutex
+	revoked map[string]bool
+}
+
+// NewMemoryRevocationList returns a list in which the given credentials are
+// revoked.
+func NewMemoryRevocationList(ids ...string) *MemoryRevocationList {
+	l := &MemoryRevocationList{revoked: make(map[string]bool)}
+	for _, id := range ids {
+		l.revoked[id] = true
+	}
+
+	return l
+}
+
+// Revoke revokes the credential with the given ID.
+func (l *MemoryRevocationList) Revoke(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.revoked[id] = true
+}
+
+// Reinstate lifts the revocation of the credential with the given ID.
+func (l *MemoryRevocationList) Reinstate(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.revoked, id)
+}
+
+// Revoked implements RevocationList.
+func (l *MemoryRevocationList) Revoked(id string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.revoked[id]
+}
+
+// List returns the revoked credential IDs in lexical order.
+func (l *MemoryRevocationList) List() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	ids := make([]string, 0, len(l.revoked))
+	for id := range l.revoked {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	return ids
+}
+
+// checkStatus returns an error if credential is revoked or expired at now.
+func (a *Authenticator) checkStatus(credential *Credential, now time.Time) *ValidationError {
+	if credential.Revoked || (a.revocations != nil && a.revocations.Revoked(credential.ID)) {
+		return &ValidationError{
+			Code:    http.StatusForbidden,
+			Reason:  ReasonCredentialRevoked,
+			Message: "Credential revoked",
+		}
+	}
+
+	if !credential.ExpiresAt.IsZero() && !now.Before(credential.ExpiresAt) {
+		return &ValidationError{
+			Code:    http.StatusForbidden,
+			Reason:  ReasonCredentialExpired,
+			Message: "Credential expired",
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package hmac
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestThatAuthenticateRejectsRevokedCredential(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	revocations := NewMemoryRevocationList()
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithRevocationList(revocations))
+
+	if _, err := authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey)); err != nil {
+		t.Fatal(err)
+	}
+
+	revocations.Revoke(publicKey)
+	_, err := authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey))
+	assertValidationError(t, err, "Credential revoked")
+	if err.(*ValidationError).Reason != ReasonCredentialRevoked {
+		t.Fatalf("expected reason %s, got %s", ReasonCredentialRevoked, err.(*ValidationError).Reason)
+	}
+
+	revocations.Reinstate(publicKey)
+	if _, err := authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThatAuthenticateRejectsRevokedCredentialFromStore(t *testing.T) {
+	clientPublicKey := GenerateSecureRandom(16)
+	clientPrivateKey := GenerateSecureRandom(16)
+	credential, _ := NewCredential(clientPublicKey, clientPrivateKey)
+	credential.Revoked = true
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300,
+		WithCredentialStore(memoryCredentialStore{clientPublicKey: credential}))
+
+	_, err := authenticator.Authenticate(signedTestRequest(t, clientPublicKey, clientPrivateKey))
+
+	assertValidationError(t, err, "Credential revoked")
+}
+
+func TestThatAuthenticateRejectsExpiredCredential(t *testing.T) {
+	clientPublicKey := GenerateSecureRandom(16)
+	clientPrivateKey := GenerateSecureRandom(16)
+	now := time.Unix(1700000000, 0)
+	credential, _ := NewCredential(clientPublicKey, clientPrivateKey)
+	credential.ExpiresAt = now.Add(time.Hour)
+	clock := func() time.Time { return now }
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300,
+		WithCredentialStore(memoryCredentialStore{clientPublicKey: credential}),
+		WithClock(clock))
+	requestService, _ := NewRequestService(clientPublicKey, clientPrivateKey, WithSigningClock(clock))
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080", nil)
+
+	signedRequest, _ := requestService.SignRequest(request.Clone(request.Context()))
+	if _, err := authenticator.Authenticate(signedRequest); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Hour)
+	signedRequest, _ = requestService.SignRequest(request.Clone(request.Context()))
+	_, err := authenticator.Authenticate(signedRequest)
+	assertValidationError(t, err, "Credential expired")
+}
+
+func TestThatRevocationIsCheckedAfterSignature(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithRevocationList(NewMemoryRevocationList(publicKey)))
+
+	_, err := authenticator.Authenticate(signedTestRequest(t, publicKey, GenerateSecureRandom(16)))
+
+	assertValidationError(t, err, "Not authorized")
+}
+
+func TestThatMemoryRevocationListListsRevokedCredentials(t *testing.T) {
+	revocations := NewMemoryRevocationList("b", "a")
+	revocations.Revoke("c")
+	revocations.Reinstate("b")
+
+	if ids := revocations.List(); len(ids) != 2 || ids[0] != "a" || ids[1] != "c" {
+		t.Fatalf("unexpected revoked credentials %v", ids)
+	}
+}
@@ -0,0 +1,120 @@
+package hmac
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// scopeDateFormat is the layout of the date of a Scope.
+const scopeDateFormat = "20060102"
+
+// Scope restricts a signing key to one UTC day, environment and service.
+// Scoped requests carry their scope in the X-Scope header, in the form
+// "<date>/<environment>/<service>", and are signed with the key returned by
+// DeriveScopedKey for that scope instead of the private key itself.
+type Scope struct {
+	Date        string
+	Environment string
+	Service     string
+}
+
+// NewScope returns the scope of the UTC day of t.
+func NewScope(t time.Time, environment string, service string) Scope {
+	return Scope{Date: t.UTC().Format(scopeDateFormat), Environment: environment, Service: service}
+}
+
+// ParseScope parses a scope in the form returned by Scope.String.
+func ParseScope(s string) (Scope, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return Scope{}, fmt.Errorf("malformed scope %q", s)
+	}
+	if _, err := time.Parse(scopeDateFormat, parts[0]); err != nil {
+		return Scope{}, fmt.Errorf("malformed scope date %q", parts[0])
+	}
+
+	return Scope{Date: parts[0], Environment: parts[1], Service: parts[2]}, nil
+}
+
+func (s Scope) String() string {
+	return s.Date + "/" + s.Environment + "/" + s.Service
+}
+
+// DeriveScopedKey derives the signing key of scope from a private key by
+// chaining HMAC-SHA256 over the date, environment and service. A key service
+// can hand the scoped key to a service that validates requests of that scope
+// without exposing the private key, in a Credential with Scope set.
+func DeriveScopedKey(private []byte, scope Scope) []byte {
+	key := []byte("HMAC" + string(private))
+	for _, part := range []string{scope.Date, scope.Environment, scope.Service, "signed-request"} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+
+	return key
+}
+
+// CreateScopedSignature signs a canonical request with a key returned by
+// DeriveScopedKey.
+func CreateScopedSignature(canonicalRequest string, timestamp int64, scopedKey []byte) string {
+	signature := hmac.New(sha256.New, scopedKey)
+	signature.Write([]byte(CreateStringToSign(canonicalRequest, timestamp)))
+
+	return base64.StdEncoding.EncodeToString(signature.Sum(nil))
+}
+
+// createSignature signs a canonical request with the key of a credential.
+// scope is the X-Scope header of the request and credentialScope the Scope
+// of the credential, whose key is then already scoped.
+func createSignature(canonicalRequest string, timestamp int64, private []byte, credentialScope string, scope string) string {
+	switch {
+	case scope == "":
+		return CreateSignature(canonicalRequest, timestamp, string(private))
+	case credentialScope != "":
+		return CreateScopedSignature(canonicalRequest, timestamp, private)
+	default:
+		parsed, _ := ParseScope(scope)
+		return CreateScopedSignature(canonicalRequest, timestamp, DeriveScopedKey(private, parsed))
+	}
+}
+
+// checkScope checks the X-Scope header of a request signed at timestamp by
+// credential against the scope required by the Authenticator, if any.
+func (a *Authenticator) checkScope(header string, timestamp int64, credential *Credential) *ValidationError {
+	invalid := func(message string) *ValidationError {
+		return &ValidationError{
+			Code:    http.StatusForbidden,
+			Reason:  ReasonInvalidScope,
+			Message: message,
+		}
+	}
+
+	if header == "" {
+		if a.scope != nil || credential.Scope != "" {
+			return invalid("Scope required")
+		}
+		return nil
+	}
+
+	scope, err := ParseScope(header)
+	if err != nil {
+		return invalid("Invalid scope")
+	}
+	if scope.Date != time.Unix(timestamp, 0).UTC().Format(scopeDateFormat) {
+		return invalid("Scope date does not match timestamp")
+	}
+	if a.scope != nil && (scope.Environment != a.scope.Environment || scope.Service != a.scope.Service) {
+		return invalid("Scope not accepted")
+	}
+	if credential.Scope != "" && credential.Scope != header {
+		return invalid("Scope not accepted")
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package hmac
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestThatParseScopeRoundTrips(t *testing.T) {
+	scope := NewScope(time.Date(2026, 10, 19, 23, 0, 0, 0, time.FixedZone("", -3600)), "production", "orders")
+
+	parsed, err := ParseScope(scope.String())
+
+	if err != nil || parsed != scope || scope.String() != "20261020/production/orders" {
+		t.Fatalf("unexpected scope %v, %v, %v", scope, parsed, err)
+	}
+}
+
+func TestThatParseScopeRejectsMalformedScopes(t *testing.T) {
+	for _, s := range []string{"", "20261019/production", "2026-10-19/production/orders", "20261019//orders", "20261019/a/b/c"} {
+		if _, err := ParseScope(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func scopedTestRequest(t *testing.T, publicKey string, privateKey string, environment string, service string) *http.Request {
+	t.Helper()
+
+	requestService, _ := NewRequestService(publicKey, privateKey, WithSigningScope(environment, service))
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/orders", nil)
+	signedRequest, err := requestService.SignRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signedRequest
+}
+
+func TestThatScopedRequestIsValidatedWithPrivateKey(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300, WithScope("production", "orders"))
+
+	result, err := authenticator.Authenticate(scopedTestRequest(t, publicKey, privateKey, "production", "orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SignedHeaders["X-Scope"] != NewScope(time.Now(), "production", "orders").String() {
+		t.Fatalf("expected signed X-Scope, got %v", result.SignedHeaders)
+	}
+
+	_, err = authenticator.Authenticate(scopedTestRequest(t, publicKey, privateKey, "production", "billing"))
+	assertValidationError(t, err, "Scope not accepted")
+
+	_, err = authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey))
+	assertValidationError(t, err, "Scope required")
+}
+
+func TestThatScopedCredentialOnlyValidatesItsScope(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	credential, _ := NewCredential(publicKey, privateKey)
+	scope := NewScope(time.Now(), "production", "orders")
+	scoped := &Credential{ID: publicKey, Private: DeriveScopedKey(credential.Private, scope), Scope: scope.String()}
+	edge, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300,
+		WithCredentialStore(memoryCredentialStore{publicKey: scoped}))
+
+	if _, err := edge.Authenticate(scopedTestRequest(t, publicKey, privateKey, "production", "orders")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := edge.Authenticate(scopedTestRequest(t, publicKey, privateKey, "staging", "orders"))
+	assertValidationError(t, err, "Scope not accepted")
+
+	_, err = edge.Authenticate(signedTestRequest(t, publicKey, privateKey))
+	assertValidationError(t, err, "Scope required")
+}
+
+func TestThatScopeMustMatchTimestampDate(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	request := scopedTestRequest(t, publicKey, privateKey, "production", "orders")
+	request.Header.Set("X-Scope", "20000101/production/orders")
+
+	_, err := authenticator.Authenticate(request)
+
+	assertValidationError(t, err, "Scope date does not match timestamp")
+}
+
+func TestThatTamperedScopeInvalidatesSignature(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	request := scopedTestRequest(t, publicKey, privateKey, "production", "orders")
+	request.Header.Set("X-Scope", NewScope(time.Now(), "production", "billing").String())
+
+	_, err := authenticator.Authenticate(request)
+
+	assertValidationError(t, err, "Not authorized")
+}
@@ -0,0 +1,119 @@
+package hmac
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// MinSecretKeyLength is the minimum length in bytes of a SecretKey, that of
+// the keys returned by GenerateSecureRandom(16).
+const MinSecretKeyLength = 16
+
+const redacted = "[REDACTED]"
+
+// SecretKey is a private key that never reveals itself when formatted with
+// fmt, logged with slog or encoded as JSON. It is a Signer and Verifier, so
+// it can be passed to NewRequestServiceWithSigner,
+// NewAuthenticatorWithVerifier and Credential.Verifier. Copies of a SecretKey
+// share its memory, which Zero overwrites.
+type SecretKey struct {
+	key []byte
+}
+
+// NewSecretKey returns a SecretKey holding a copy of key. It returns an error
+// if key is shorter than MinSecretKeyLength.
+func NewSecretKey(key []byte) (SecretKey, error) {
+	if len(key) < MinSecretKeyLength {
+		return SecretKey{}, fmt.Errorf("private key must be at least %d bytes", MinSecretKeyLength)
+	}
+
+	return SecretKey{key: append([]byte(nil), key...)}, nil
+}
+
+// ParseSecretKey parses a hex, base64 or base64url encoded private key,
+// padded or not. Keys that are valid hex are decoded as hex.
+func ParseSecretKey(s string) (SecretKey, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return SecretKey{}, fmt.Errorf("private key required")
+	}
+
+	decoders := []func(string) ([]byte, error){
+		hex.DecodeString,
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		base64.URLEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+	}
+	for _, decode := range decoders {
+		b, err := decode(s)
+		if err != nil {
+			clear(b)
+			continue
+		}
+
+		key, err := NewSecretKey(b)
+		clear(b)
+		return key, err
+	}
+
+	return SecretKey{}, fmt.Errorf("malformed private key")
+}
+
+// Bytes returns the key. The returned slice is overwritten by Zero and must
+// not be retained or modified.
+func (k SecretKey) Bytes() []byte {
+	return k.key
+}
+
+// Zero overwrites the key with zeros, in every copy of k. Call it once the
+// key is no longer used, for example when its credential is removed.
+func (k SecretKey) Zero() {
+	clear(k.key)
+}
+
+// Sign implements Signer.
+func (k SecretKey) Sign(canonicalRequest string, timestamp int64, scope string) (string, error) {
+	if scope != "" {
+		if _, err := ParseScope(scope); err != nil {
+			return "", err
+		}
+	}
+
+	return createSignature(canonicalRequest, timestamp, k.key, "", scope), nil
+}
+
+// Verify implements Verifier.
+func (k SecretKey) Verify(canonicalRequest string, timestamp int64, scope string, signature string) (bool, error) {
+	expected, err := k.Sign(canonicalRequest, timestamp, scope)
+	if err != nil {
+		return false, err
+	}
+
+	return hmac.Equal([]byte(expected), []byte(signature)), nil
+}
+
+// String returns a placeholder instead of the key.
+func (k SecretKey) String() string {
+	return redacted
+}
+
+// Format implements fmt.Formatter, so that no verb prints the key.
+func (k SecretKey) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, redacted)
+}
+
+// LogValue implements slog.LogValuer. The key is never included.
+func (k SecretKey) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+// MarshalJSON encodes a placeholder instead of the key.
+func (k SecretKey) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
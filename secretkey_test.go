@@ -0,0 +1,163 @@
+package hmac
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThatParseSecretKeyAcceptsEncodings(t *testing.T) {
+	privateKey := GenerateSecureRandom(16)
+	private, _ := hex.DecodeString(privateKey)
+
+	for _, s := range []string{
+		privateKey,
+		strings.ToUpper(privateKey),
+		base64.StdEncoding.EncodeToString(private),
+		base64.RawStdEncoding.EncodeToString(private),
+		base64.URLEncoding.EncodeToString(private),
+		base64.RawURLEncoding.EncodeToString(private),
+		" " + privateKey + "\n",
+	} {
+		key, err := ParseSecretKey(s)
+		if err != nil || !bytes.Equal(key.Bytes(), private) {
+			t.Errorf("unexpected key for %q: %v", s, err)
+		}
+	}
+}
+
+func TestThatParseSecretKeyRejectsShortAndMalformedKeys(t *testing.T) {
+	for _, s := range []string{"", GenerateSecureRandom(15), "not a key!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParseSecretKey(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+
+	if _, err := NewSecretKey(make([]byte, MinSecretKeyLength-1)); err == nil {
+		t.Fatal("expected error for short key")
+	}
+}
+
+func TestThatSecretKeyIsRedacted(t *testing.T) {
+	key, _ := ParseSecretKey(GenerateSecureRandom(16))
+	secret := hex.EncodeToString(key.Bytes())
+
+	var out bytes.Buffer
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%x", "%d", "%q"} {
+		fmt.Fprintf(&out, format+"\n", key)
+	}
+	fmt.Fprintf(&out, "%+v\n", struct{ Key SecretKey }{key})
+	slog.New(slog.NewJSONHandler(&out, nil)).Info("key", "key", key)
+	encoded, _ := json.Marshal(map[string]SecretKey{"key": key})
+	out.Write(encoded)
+
+	if strings.Contains(out.String(), secret) || strings.Contains(out.String(), fmt.Sprint(key.Bytes())) {
+		t.Fatalf("expected key to be redacted, got %s", out.String())
+	}
+	if !strings.Contains(string(encoded), `"[REDACTED]"`) {
+		t.Fatalf("unexpected JSON %s", encoded)
+	}
+}
+
+func TestThatZeroOverwritesSharedKey(t *testing.T) {
+	key, _ := NewSecretKey(bytes.Repeat([]byte{1}, 32))
+	credential := &Credential{ID: "client", Verifier: key}
+
+	credential.Zero()
+
+	if !bytes.Equal(key.Bytes(), make([]byte, 32)) {
+		t.Fatalf("expected key to be zeroed, got %x", key.Bytes())
+	}
+}
+
+func TestThatSecretKeySignsAndVerifiesRequests(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	key, _ := ParseSecretKey(privateKey)
+
+	requestService, _ := NewRequestServiceWithSigner(publicKey, key, WithSigningScope("production", "orders"))
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/orders", nil)
+	if _, err := requestService.SignRequest(request); err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	if _, err := authenticator.Authenticate(request); err != nil {
+		t.Fatalf("expected private key to validate secret key request, got %v", err)
+	}
+
+	authenticator, _ = NewAuthenticatorWithVerifier(publicKey, key, 300)
+	if _, err := authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey)); err != nil {
+		t.Fatalf("expected secret key to validate request, got %v", err)
+	}
+
+	key.Zero()
+	_, err := authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey))
+	assertValidationError(t, err, "Not authorized")
+
+	if _, err := key.Sign("", time.Now().Unix(), "invalid"); err == nil {
+		t.Fatal("expected error for malformed scope")
+	}
+}
+
+func TestThatCredentialFormattingAndJSONOmitPrivateKey(t *testing.T) {
+	privateKey := base64.URLEncoding.EncodeToString(bytes.Repeat([]byte{0xfb}, 16))
+	credential, err := NewCredential("client", privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := json.Marshal(credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, _ := json.Marshal(*credential)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%+v %v %#v %x", *credential, *credential, credential, *credential)
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("credential", "credential", *credential)
+	output := string(encoded) + string(value) + buf.String()
+
+	for _, leak := range []string{base64.StdEncoding.EncodeToString(credential.Private), hex.EncodeToString(credential.Private), fmt.Sprint(credential.Private)} {
+		if strings.Contains(output, leak) {
+			t.Fatalf("expected no private key in output, got %s", output)
+		}
+	}
+	if !strings.Contains(string(encoded), `"ID":"client"`) || !strings.Contains(string(encoded), `"Private":"[REDACTED]"`) {
+		t.Fatalf("unexpected JSON %s", encoded)
+	}
+}
+
+func TestThatConstructorsAcceptEncodingsAndRejectShortKeys(t *testing.T) {
+	private := bytes.Repeat([]byte{0xfe}, 16)
+	for _, privateKey := range []string{hex.EncodeToString(private), base64.StdEncoding.EncodeToString(private), base64.RawURLEncoding.EncodeToString(private)} {
+		credential, err := NewCredential("client", privateKey)
+		if err != nil || !bytes.Equal(credential.Private, private) {
+			t.Fatalf("unexpected credential for %q: %v", privateKey, err)
+		}
+		if _, err := NewRequestService("client", privateKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	short := GenerateSecureRandom(8)
+	if _, err := NewCredential("client", short); err == nil {
+		t.Fatal("expected error for short credential key")
+	}
+	if _, err := NewRequestService("client", short); err == nil {
+		t.Fatal("expected error for short request service key")
+	}
+	if _, err := CredentialsFromEnv("HMAC_TEST_SHORT_")(); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HMAC_TEST_SHORT_CLIENT", "client:"+short)
+	if _, err := CredentialsFromEnv("HMAC_TEST_SHORT_")(); err == nil {
+		t.Fatal("expected loader to reject short key")
+	}
+}
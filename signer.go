@@ -0,0 +1,224 @@
+package hmac
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+)
+
+// Signer signs canonical requests with a private key it holds. scope is the
+// X-Scope header of the request, empty for unscoped requests.
+type Signer interface {
+	Sign(canonicalRequest string, timestamp int64, scope string) (string, error)
+}
+
+// Verifier verifies signatures made with a private key it holds.
+type Verifier interface {
+	Verify(canonicalRequest string, timestamp int64, scope string, signature string) (bool, error)
+}
+
+// MACFunc returns the HMAC-SHA256 of message keyed with "HMAC" followed by
+// a private key. It is the only step of signing that uses the private key;
+// the signing key is derived from its result. A MACFunc backed by a KMS or
+// HSM holding that key keeps the private key out of process memory.
+type MACFunc func(message []byte) ([]byte, error)
+
+// LocalMAC returns a MACFunc for a private key held in process memory, as a
+// stand-in for a KMS in tests.
+func LocalMAC(private []byte) MACFunc {
+	key := []byte("HMAC" + string(private))
+	return func(message []byte) ([]byte, error) {
+		return hmacSHA256(key, message), nil
+	}
+}
+
+// Key is a Signer and Verifier that computes signatures with a MACFunc. The
+// MACFunc is called with the timestamp of unscoped requests and the date of
+// scoped ones, and its results are cached, so that a remote MACFunc is called
+// about once per second, or once per day, rather than once per request. The
+// environment and service of a scope are derived in process, so requests of
+// other scopes cannot evict cached results.
+type Key struct {
+	mac       MACFunc
+	mu        sync.Mutex
+	cache     map[string][]byte
+	order     []string
+	cacheSize int
+	scope     *Scope
+}
+
+type KeyOption func(*Key)
+
+// WithKeyCacheSize sets the number of MACFunc results cached by a Key. The
+// default is 1024; 0 disables caching.
+func WithKeyCacheSize(size int) KeyOption {
+	return func(k *Key) {
+		k.cacheSize = size
+	}
+}
+
+// WithKeyScope restricts a Key to scoped requests of the given environment
+// and service. Signing any other request fails, and its signature is invalid,
+// without calling the MACFunc.
+func WithKeyScope(environment string, service string) KeyOption {
+	return func(k *Key) {
+		k.scope = &Scope{Environment: environment, Service: service}
+	}
+}
+
+// NewKey returns a Key that computes signatures with mac.
+func NewKey(mac MACFunc, options ...KeyOption) *Key {
+	k := &Key{
+		mac:       mac,
+		cache:     make(map[string][]byte),
+		cacheSize: 1024,
+	}
+
+	for _, option := range options {
+		option(k)
+	}
+
+	return k
+}
+
+// Sign implements Signer.
+func (k *Key) Sign(canonicalRequest string, timestamp int64, scope string) (string, error) {
+	key, err := k.signingKey(timestamp, scope)
+	if err != nil {
+		return "", err
+	}
+
+	return CreateScopedSignature(canonicalRequest, timestamp, key), nil
+}
+
+// Verify implements Verifier.
+func (k *Key) Verify(canonicalRequest string, timestamp int64, scope string, signature string) (bool, error) {
+	if k.checkScope(scope) != nil {
+		return false, nil
+	}
+
+	expected, err := k.Sign(canonicalRequest, timestamp, scope)
+	if err != nil {
+		return false, err
+	}
+
+	return hmac.Equal([]byte(expected), []byte(signature)), nil
+}
+
+// String returns a placeholder instead of the cached signing keys.
+func (k *Key) String() string {
+	return "Key{" + redacted + "}"
+}
+
+// Format implements fmt.Formatter, so that no verb prints the cached signing
+// keys or the MACFunc.
+func (k *Key) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, k.String())
+}
+
+// LogValue implements slog.LogValuer. The cached signing keys are never
+// included.
+func (k *Key) LogValue() slog.Value {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return slog.GroupValue(
+		slog.Int("cached_macs", len(k.cache)),
+		slog.Int("cache_size", k.cacheSize),
+	)
+}
+
+// checkScope returns an error unless scope is accepted by the Key.
+func (k *Key) checkScope(scope string) error {
+	if k.scope == nil {
+		if scope == "" {
+			return nil
+		}
+		_, err := ParseScope(scope)
+		return err
+	}
+
+	parsed, err := ParseScope(scope)
+	if err != nil {
+		return err
+	}
+	if parsed.Environment != k.scope.Environment || parsed.Service != k.scope.Service {
+		return fmt.Errorf("scope %q not accepted by key", scope)
+	}
+
+	return nil
+}
+
+// signingKey returns the key that signs the string to sign of a request with
+// the given timestamp and scope, as derived by CreateSignature and
+// DeriveScopedKey.
+func (k *Key) signingKey(timestamp int64, scope string) ([]byte, error) {
+	if err := k.checkScope(scope); err != nil {
+		return nil, err
+	}
+
+	parts := []string{strconv.FormatInt(timestamp, 10), "signed-request"}
+	if scope != "" {
+		parsed, _ := ParseScope(scope)
+		parts = []string{parsed.Date, parsed.Environment, parsed.Service, "signed-request"}
+	}
+
+	key, err := k.remoteMAC(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts[1:] {
+		key = hmacSHA256(key, []byte(part))
+	}
+
+	return key, nil
+}
+
+// remoteMAC returns the result of the MACFunc for message, from the cache if
+// possible.
+func (k *Key) remoteMAC(message string) ([]byte, error) {
+	k.mu.Lock()
+	key, ok := k.cache[message]
+	k.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	key, err := k.mac([]byte(message))
+	if err != nil {
+		return nil, err
+	}
+
+	k.store(message, key)
+	return key, nil
+}
+
+// store caches key, evicting the oldest keys once the cache is full.
+func (k *Key) store(cacheKey string, key []byte) {
+	if k.cacheSize <= 0 {
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.cache[cacheKey]; ok {
+		return
+	}
+	for len(k.order) >= k.cacheSize {
+		delete(k.cache, k.order[0])
+		k.order = k.order[1:]
+	}
+	k.cache[cacheKey] = key
+	k.order = append(k.order, cacheKey)
+}
+
+func hmacSHA256(key []byte, message []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
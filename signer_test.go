@@ -0,0 +1,217 @@
+package hmac
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingMAC wraps LocalMAC and counts its calls.
+func countingMAC(private []byte, calls *int) MACFunc {
+	mac := LocalMAC(private)
+	return func(message []byte) ([]byte, error) {
+		*calls++
+		return mac(message)
+	}
+}
+
+func TestThatKeyMatchesPrivateKeySignatures(t *testing.T) {
+	private, _ := hex.DecodeString(GenerateSecureRandom(16))
+	key := NewKey(LocalMAC(private))
+	canonicalRequest := CreateCanonicalRequestString(http.MethodGet, "localhost:8080", "/orders", "", map[string]string{"X-Nonce": "abc"})
+	timestamp := time.Now().Unix()
+
+	signature, err := key.Sign(canonicalRequest, timestamp, "")
+	if err != nil || signature != CreateSignature(canonicalRequest, timestamp, string(private)) {
+		t.Fatalf("unexpected signature %q, %v", signature, err)
+	}
+
+	scope := NewScope(time.Unix(timestamp, 0), "production", "orders")
+	signature, err = key.Sign(canonicalRequest, timestamp, scope.String())
+	if err != nil || signature != CreateScopedSignature(canonicalRequest, timestamp, DeriveScopedKey(private, scope)) {
+		t.Fatalf("unexpected scoped signature %q, %v", signature, err)
+	}
+
+	if valid, err := key.Verify(canonicalRequest, timestamp, scope.String(), signature); !valid || err != nil {
+		t.Fatalf("expected valid signature, got %v, %v", valid, err)
+	}
+	if valid, err := key.Verify(canonicalRequest, timestamp, "", signature); valid || err != nil {
+		t.Fatalf("expected invalid signature, got %v, %v", valid, err)
+	}
+}
+
+func TestThatKeyCachesDerivedKeys(t *testing.T) {
+	private, _ := hex.DecodeString(GenerateSecureRandom(16))
+	calls := 0
+	key := NewKey(countingMAC(private, &calls), WithKeyCacheSize(2))
+	timestamp := time.Now().Unix()
+	scope := NewScope(time.Unix(timestamp, 0), "production", "orders").String()
+
+	for i := range 3 {
+		_, _ = key.Sign("a", timestamp, "")
+		_, _ = key.Sign("b", timestamp+int64(i), scope)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 MAC calls, got %d", calls)
+	}
+
+	_, _ = key.Sign("a", timestamp+1, "")
+	_, _ = key.Sign("a", timestamp, "")
+	if calls != 4 {
+		t.Fatalf("expected the oldest key to be evicted, got %d MAC calls", calls)
+	}
+
+	calls = 0
+	uncached := NewKey(countingMAC(private, &calls), WithKeyCacheSize(0))
+	_, _ = uncached.Sign("a", timestamp, "")
+	_, _ = uncached.Sign("a", timestamp, "")
+	if calls != 2 {
+		t.Fatalf("expected 2 MAC calls without a cache, got %d", calls)
+	}
+}
+
+func TestThatKeyCachesByDateAcrossScopes(t *testing.T) {
+	private, _ := hex.DecodeString(GenerateSecureRandom(16))
+	calls := 0
+	key := NewKey(countingMAC(private, &calls), WithKeyCacheSize(2))
+	timestamp := time.Now().Unix()
+
+	for i := range 2000 {
+		scope := NewScope(time.Unix(timestamp, 0), "production", fmt.Sprintf("service-%d", i))
+		signature, err := key.Sign("a", timestamp, scope.String())
+		if err != nil || signature != CreateScopedSignature("a", timestamp, DeriveScopedKey(private, scope)) {
+			t.Fatalf("unexpected signature %q, %v", signature, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 MAC call, got %d", calls)
+	}
+}
+
+func TestThatKeyRejectsOtherScopesWithoutCallingMAC(t *testing.T) {
+	private, _ := hex.DecodeString(GenerateSecureRandom(16))
+	calls := 0
+	key := NewKey(countingMAC(private, &calls), WithKeyScope("production", "orders"))
+	timestamp := time.Now().Unix()
+	other := NewScope(time.Unix(timestamp, 0), "production", "billing").String()
+
+	if _, err := key.Sign("a", timestamp, other); err == nil {
+		t.Fatal("expected signing another scope to fail")
+	}
+	if _, err := key.Sign("a", timestamp, ""); err == nil {
+		t.Fatal("expected signing an unscoped request to fail")
+	}
+	if valid, err := key.Verify("a", timestamp, other, "signature"); valid || err != nil {
+		t.Fatalf("expected invalid signature, got %v, %v", valid, err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no MAC calls, got %d", calls)
+	}
+
+	scope := NewScope(time.Unix(timestamp, 0), "production", "orders").String()
+	signature, err := key.Sign("a", timestamp, scope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid, err := key.Verify("a", timestamp, scope, signature); !valid || err != nil {
+		t.Fatalf("expected valid signature, got %v, %v", valid, err)
+	}
+}
+
+func TestThatRequestSignedWithSignerIsValidatedWithVerifier(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	private, _ := hex.DecodeString(privateKey)
+
+	requestService, err := NewRequestServiceWithSigner(publicKey, NewKey(LocalMAC(private)), WithSigningScope("production", "orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/orders", nil)
+	if _, err := requestService.SignRequest(request); err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	if _, err := authenticator.Authenticate(request); err != nil {
+		t.Fatalf("expected private key to validate signer request, got %v", err)
+	}
+
+	authenticator, err = NewAuthenticatorWithVerifier(publicKey, NewKey(LocalMAC(private)), 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticator.Authenticate(request); err != nil {
+		t.Fatalf("expected verifier to validate request, got %v", err)
+	}
+	if _, err := authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey)); err != nil {
+		t.Fatalf("expected verifier to validate unscoped request, got %v", err)
+	}
+	if diagnosis := authenticator.Diagnose(signedTestRequest(t, publicKey, privateKey), time.Now()); !diagnosis.OK() || !diagnosis.Explanation.SignatureMatches() {
+		t.Fatalf("expected diagnosis to explain signature, got %+v", diagnosis)
+	}
+
+	_, err = authenticator.Authenticate(signedTestRequest(t, publicKey, GenerateSecureRandom(16)))
+	assertValidationError(t, err, "Not authorized")
+}
+
+func TestThatCredentialVerifierIsUsed(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+	private, _ := hex.DecodeString(privateKey)
+	store := memoryCredentialStore{publicKey: {ID: publicKey, Verifier: NewKey(LocalMAC(private))}}
+	authenticator, _ := NewAuthenticator(GenerateSecureRandom(16), GenerateSecureRandom(16), 300, WithCredentialStore(store))
+
+	if _, err := authenticator.Authenticate(signedTestRequest(t, publicKey, privateKey)); err != nil {
+		t.Fatalf("expected credential verifier to validate request, got %v", err)
+	}
+}
+
+func TestThatVerifierErrorsAreRejected(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	failing := NewKey(func([]byte) ([]byte, error) {
+		return nil, errors.New("kms unavailable")
+	})
+
+	authenticator, _ := NewAuthenticatorWithVerifier(publicKey, failing, 300)
+	_, err := authenticator.Authenticate(signedTestRequest(t, publicKey, GenerateSecureRandom(16)))
+	assertValidationError(t, err, "Unable to verify signature")
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Code != http.StatusInternalServerError || validationErr.Reason != ReasonVerifierFailed {
+		t.Fatalf("unexpected error %+v", validationErr)
+	}
+
+	requestService, _ := NewRequestServiceWithSigner(publicKey, failing)
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost:8080/orders", nil)
+	if _, err := requestService.SignRequest(request); err == nil {
+		t.Fatal("expected signing error")
+	}
+}
+
+func TestThatKeyFormattingOmitsSigningKeys(t *testing.T) {
+	private, _ := hex.DecodeString(GenerateSecureRandom(16))
+	key := NewKey(LocalMAC(private))
+	timestamp := time.Now().Unix()
+	key.Sign("a", timestamp, "")
+	signingKey, _ := key.signingKey(timestamp, "")
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("key", "key", key)
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("key", "key", key)
+	fmt.Fprintf(&buf, "%v %+v %#v %s %x", key, key, key, key, key)
+
+	for _, leak := range []string{fmt.Sprint(signingKey), hex.EncodeToString(signingKey), string(signingKey)} {
+		if strings.Contains(buf.String(), leak) {
+			t.Fatalf("expected no signing key in output, got %s", buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "cached_macs=1") {
+		t.Fatalf("expected cache size in log, got %s", buf.String())
+	}
+}
@@ -0,0 +1,88 @@
+package hmac
+
+import (
+	"net"
+	"net/http"
+	"net/http/httputil"
+)
+
+// ProxyUpstream is an upstream of a signing proxy: the RequestService that
+// signs its requests and the Scheme of the connection to it, "https" by
+// default.
+type ProxyUpstream struct {
+	Signer *RequestService
+	Scheme string
+}
+
+// NewSigningProxy returns an HTTP forward proxy that signs requests with
+// the RequestService of their upstream host and forwards them, so that any
+// tool honoring HTTP_PROXY can call HMAC-protected APIs. upstreams is keyed
+// by host, with or without port; a host:port entry takes precedence.
+//
+// The proxy only accepts requests from loopback addresses and only forwards
+// to hosts in upstreams. It cannot sign HTTPS requests, which are tunneled
+// with CONNECT and encrypted end to end, so tools must use http:// URLs for
+// the upstreams it signs. The proxy connects to the upstream with the scheme
+// of its ProxyUpstream instead, so that signed requests are only sent in
+// plain text to upstreams configured with "http".
+func NewSigningProxy(upstreams map[string]ProxyUpstream) http.Handler {
+	// The proxy may run with HTTP_PROXY pointing at itself.
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = nil
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			upstream, _ := hostUpstream(upstreams, pr.In.URL.Host)
+			pr.Out.URL.Scheme = upstream.scheme()
+			pr.Out.Host = pr.Out.URL.Host
+		},
+		Transport: &signingTransport{
+			signer: func(host string) *RequestService {
+				upstream, _ := hostUpstream(upstreams, host)
+				return upstream.Signer
+			},
+			base: base,
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			http.Error(w, "Proxy only accepts requests from localhost", http.StatusForbidden)
+			return
+		}
+
+		if r.Method == http.MethodConnect || !r.URL.IsAbs() {
+			http.Error(w, "Only plain HTTP proxy requests can be signed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if upstream, ok := hostUpstream(upstreams, r.URL.Host); !ok || upstream.Signer == nil {
+			http.Error(w, "No credentials for host "+r.URL.Host, http.StatusForbidden)
+			return
+		}
+
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+func (u ProxyUpstream) scheme() string {
+	if u.Scheme == "" {
+		return "https"
+	}
+
+	return u.Scheme
+}
+
+func hostUpstream(upstreams map[string]ProxyUpstream, host string) (ProxyUpstream, bool) {
+	if upstream, ok := upstreams[host]; ok {
+		return upstream, true
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		upstream, ok := upstreams[hostname]
+		return upstream, ok
+	}
+
+	return ProxyUpstream{}, false
+}
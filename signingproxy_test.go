@@ -0,0 +1,97 @@
+package hmac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestThatSigningProxySignsRequestsForConfiguredHost(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	authenticator, _ := NewAuthenticator(publicKey, privateKey, 300)
+	var result *Result
+	upstream := httptest.NewServer(authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, _ = FromContext(r.Context())
+	})))
+	defer upstream.Close()
+	upstreamURL, _ := url.Parse(upstream.URL)
+
+	requestService, _ := NewRequestService(publicKey, privateKey)
+	proxy := httptest.NewServer(NewSigningProxy(map[string]ProxyUpstream{upstreamURL.Host: {Signer: requestService, Scheme: "http"}}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	response, err := client.Post(upstream.URL+"/items?abc=xyz", "application/json", strings.NewReader(`{"foo": "bar"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK || result == nil || result.Credential != publicKey {
+		t.Fatalf("expected upstream to authenticate proxied request, got status %d", response.StatusCode)
+	}
+}
+
+func TestThatSigningProxyConnectsToUpstreamsOverHTTPSByDefault(t *testing.T) {
+	publicKey := GenerateSecureRandom(16)
+	privateKey := GenerateSecureRandom(16)
+
+	called := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer upstream.Close()
+	upstreamURL, _ := url.Parse(upstream.URL)
+
+	requestService, _ := NewRequestService(publicKey, privateKey)
+	proxy := httptest.NewServer(NewSigningProxy(map[string]ProxyUpstream{upstreamURL.Host: {Signer: requestService}}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	response, err := client.Get(upstream.URL + "/items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if called || response.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected the proxy to refuse plain HTTP to the upstream, got status %d", response.StatusCode)
+	}
+}
+
+func TestThatSigningProxyRejectsUnknownHost(t *testing.T) {
+	proxy := httptest.NewServer(NewSigningProxy(map[string]ProxyUpstream{}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	response, err := client.Get("http://unknown.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, response.StatusCode)
+	}
+}
+
+func TestThatSigningProxyRejectsRemoteClients(t *testing.T) {
+	requestService, _ := NewRequestService(GenerateSecureRandom(16), GenerateSecureRandom(16))
+	handler := NewSigningProxy(map[string]ProxyUpstream{"api.example.com": {Signer: requestService}})
+
+	request := httptest.NewRequest(http.MethodGet, "http://api.example.com/", nil)
+	request.RemoteAddr = "203.0.113.7:5000"
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, recorder.Code)
+	}
+}